@@ -0,0 +1,207 @@
+package mecardcodec
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/emersion/go-vcard"
+)
+
+// carriedFields are the vCard fields that have a counterpart in a MeCard.
+// VERSION and FN are carried as well, because the name of a MeCard is taken from N or FN.
+var carriedFields = []string{
+	vcard.FieldVersion,
+	vcard.FieldFormattedName,
+	vcard.FieldName,
+	vcard.FieldTelephone,
+	vcard.FieldEmail,
+	vcard.FieldAddress,
+	vcard.FieldURL,
+	vcard.FieldNote,
+	vcard.FieldBirthday,
+	vcard.FieldNickname,
+	vcard.FieldOrganization,
+}
+
+// isoDate matches the extended date format of vCard, like 1990-05-17, which is written without hyphens in a MeCard.
+var isoDate = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+
+var valueFormatter = strings.NewReplacer("\\", "\\\\", ";", "\\;", ",", "\\,", ":", "\\:", "\"", "\\\"")
+
+type Codec struct {
+}
+
+func NewCodec() Codec {
+	return Codec{}
+}
+
+// Encode writes the card as a MeCard, the compact contact format of NTT DOCOMO that most QR code readers understand.
+// The fields that a MeCard can not carry are left out, Unsupported tells which ones.
+func (c *Codec) Encode(card vcard.Card) ([]byte, error) {
+	var buf strings.Builder
+	buf.WriteString("MECARD:")
+
+	if name := card.Name(); name != nil && (name.FamilyName != "" || name.GivenName != "") {
+		writeProperty(&buf, "N", formatList(name.FamilyName, name.GivenName))
+	} else if fn := strings.TrimSpace(card.Value(vcard.FieldFormattedName)); fn != "" {
+		writeProperty(&buf, "N", valueFormatter.Replace(fn))
+	}
+	for _, nickname := range card.Values(vcard.FieldNickname) {
+		writeProperty(&buf, "NICKNAME", valueFormatter.Replace(nickname))
+	}
+	for _, tel := range card.Values(vcard.FieldTelephone) {
+		writeProperty(&buf, "TEL", valueFormatter.Replace(tel))
+	}
+	for _, email := range card.Values(vcard.FieldEmail) {
+		writeProperty(&buf, "EMAIL", valueFormatter.Replace(email))
+	}
+	if org := card.Value(vcard.FieldOrganization); org != "" {
+		writeProperty(&buf, "ORG", formatList(strings.Split(org, ";")...))
+	}
+	for _, address := range card.Addresses() {
+		writeProperty(&buf, "ADR", formatList(address.PostOfficeBox, address.ExtendedAddress, address.StreetAddress, address.Locality, address.Region, address.PostalCode, address.Country))
+	}
+	for _, url := range card.Values(vcard.FieldURL) {
+		writeProperty(&buf, "URL", valueFormatter.Replace(url))
+	}
+	if birthday := card.Value(vcard.FieldBirthday); birthday != "" {
+		if isoDate.MatchString(birthday) {
+			birthday = strings.ReplaceAll(birthday, "-", "")
+		}
+		writeProperty(&buf, "BDAY", valueFormatter.Replace(birthday))
+	}
+	if note := card.Value(vcard.FieldNote); note != "" {
+		writeProperty(&buf, "NOTE", valueFormatter.Replace(note))
+	}
+
+	buf.WriteString(";")
+	return []byte(buf.String()), nil
+}
+
+// Decode reads a MeCard into a card without version. Addresses with all seven parts are read part by part,
+// other addresses are kept as street address.
+func (c *Codec) Decode(data []byte) (vcard.Card, error) {
+	text, found := strings.CutPrefix(strings.TrimSpace(string(data)), "MECARD:")
+	if !found {
+		return nil, errors.New("No MeCard found")
+	}
+
+	card := vcard.Card{}
+	for _, property := range splitEscaped(text, ';') {
+		nameAndValue := splitEscaped(property, ':')
+		if len(nameAndValue) < 2 {
+			continue
+		}
+		name := strings.ToUpper(strings.TrimSpace(nameAndValue[0]))
+		value := strings.Join(nameAndValue[1:], ":")
+		parts := splitEscaped(value, ',')
+		if len(parts) == 0 {
+			continue
+		}
+		for i := range parts {
+			parts[i] = unescape(parts[i])
+		}
+
+		switch name {
+		case "N":
+			name := &vcard.Name{FamilyName: parts[0]}
+			if len(parts) > 1 {
+				name.GivenName = strings.Join(parts[1:], " ")
+			}
+			card.SetName(name)
+		case "ORG":
+			card.SetValue(vcard.FieldOrganization, strings.Join(parts, ";"))
+		case "ADR":
+			address := &vcard.Address{StreetAddress: strings.Join(parts, ", ")}
+			if len(parts) == 7 {
+				address = &vcard.Address{PostOfficeBox: parts[0], ExtendedAddress: parts[1], StreetAddress: parts[2], Locality: parts[3], Region: parts[4], PostalCode: parts[5], Country: parts[6]}
+			}
+			card.AddAddress(address)
+		case "TEL", "EMAIL", "URL", "NICKNAME", "NOTE", "BDAY":
+			fieldName := name
+			if name == "BDAY" {
+				fieldName = vcard.FieldBirthday
+			}
+			card.Add(fieldName, &vcard.Field{Value: unescape(value)})
+		}
+	}
+
+	if len(card) == 0 {
+		return nil, errors.New("The MeCard is empty")
+	}
+	return card, nil
+}
+
+// splitEscaped splits the text at the separators that are not escaped with a backslash, the escapes are kept.
+func splitEscaped(text string, separator rune) []string {
+	parts := []string{}
+	var part strings.Builder
+	escaped := false
+	for _, r := range text {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == separator:
+			parts = append(parts, part.String())
+			part.Reset()
+			continue
+		}
+		part.WriteRune(r)
+	}
+	if part.Len() > 0 {
+		parts = append(parts, part.String())
+	}
+	return parts
+}
+
+func unescape(value string) string {
+	var unescaped strings.Builder
+	escaped := false
+	for _, r := range value {
+		if !escaped && r == '\\' {
+			escaped = true
+			continue
+		}
+		escaped = false
+		unescaped.WriteRune(r)
+	}
+	return unescaped.String()
+}
+
+// Unsupported returns the sorted names of the fields of the card that have a value, but can not be carried by a MeCard.
+func (c *Codec) Unsupported(card vcard.Card) []string {
+	unsupported := []string{}
+	for key, fields := range card {
+		if slices.Contains(carriedFields, key) {
+			continue
+		}
+		if slices.ContainsFunc(fields, func(field *vcard.Field) bool { return strings.Trim(field.Value, "; ") != "" }) {
+			unsupported = append(unsupported, key)
+		}
+	}
+	slices.Sort(unsupported)
+	return unsupported
+}
+
+// writeProperty adds a property to the MeCard, properties without a value are left out.
+func writeProperty(buf *strings.Builder, name, value string) {
+	if strings.TrimSpace(value) == "" {
+		return
+	}
+	buf.WriteString(name + ":" + value + ";")
+}
+
+// formatList joins the values that are not empty with a comma.
+func formatList(values ...string) string {
+	formatted := []string{}
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			formatted = append(formatted, valueFormatter.Replace(value))
+		}
+	}
+	return strings.Join(formatted, ",")
+}
@@ -0,0 +1,69 @@
+package mecardcodec_test
+
+import (
+	"testing"
+
+	"github.com/emersion/go-vcard"
+	"github.com/stretchr/testify/assert"
+
+	mecardcodec "github.com/ulfschneider/qrvc/internal/adapters/codec/mecard"
+	testutil "github.com/ulfschneider/qrvc/internal/test/util"
+)
+
+func TestMeCardCodec(t *testing.T) {
+	codec := mecardcodec.NewCodec()
+
+	mecard, err := codec.Encode(testutil.CreateCard())
+	assert.NoError(t, err)
+	assert.Equal(t, "MECARD:N:Family name,Given name;TEL:Cell phone;TEL:Work phone;TEL:Home phone;EMAIL:Email address;ORG:Organization or company,Department;"+
+		"ADR:Post office box,Extended street address,Street address,City,Postal code,Country;URL:Web address;;", string(mecard))
+
+	//special characters are escaped and dates lose their hyphens
+	card := vcard.Card{}
+	card.SetValue(vcard.FieldFormattedName, "Smith; Jones")
+	card.SetValue(vcard.FieldURL, "https://example.com")
+	card.SetValue(vcard.FieldBirthday, "1990-05-17")
+	mecard, err = codec.Encode(card)
+	assert.NoError(t, err)
+	assert.Equal(t, `MECARD:N:Smith\; Jones;URL:https\://example.com;BDAY:19900517;;`, string(mecard))
+}
+
+func TestMeCardCodecUnsupported(t *testing.T) {
+	codec := mecardcodec.NewCodec()
+
+	card := testutil.CreateConformingCard()
+	card.SetValue(vcard.FieldRole, "")
+	assert.Equal(t, []string{vcard.FieldGender, vcard.FieldTitle}, codec.Unsupported(card))
+	assert.Empty(t, codec.Unsupported(vcard.Card{}))
+}
+
+func TestMeCardCodecDecode(t *testing.T) {
+	codec := mecardcodec.NewCodec()
+
+	card, err := codec.Decode([]byte(`MECARD:N:Smith,John;TEL:+49 171 1;TEL:0171 2;EMAIL:john@example.com;ORG:Acme\, Inc.,Sales;` +
+		`ADR:,,Main Street 1,Berlin,,10115,Germany;URL:https\://example.com;BDAY:19900517;NOTE:First\; second;;`))
+	assert.NoError(t, err)
+	assert.Equal(t, "Smith", card.Name().FamilyName)
+	assert.Equal(t, "John", card.Name().GivenName)
+	assert.Equal(t, []string{"+49 171 1", "0171 2"}, card.Values(vcard.FieldTelephone))
+	assert.Equal(t, "john@example.com", card.Value(vcard.FieldEmail))
+	assert.Equal(t, "Acme, Inc.;Sales", card.Value(vcard.FieldOrganization))
+	assert.Equal(t, "Main Street 1", card.Address().StreetAddress)
+	assert.Equal(t, "Germany", card.Address().Country)
+	assert.Equal(t, "https://example.com", card.Value(vcard.FieldURL))
+	assert.Equal(t, "19900517", card.Value(vcard.FieldBirthday))
+	assert.Equal(t, "First; second", card.Value(vcard.FieldNote))
+
+	//what is encoded is decoded again
+	mecard, err := codec.Encode(card)
+	assert.NoError(t, err)
+	decoded, err := codec.Decode(mecard)
+	assert.NoError(t, err)
+	assert.Equal(t, card.Values(vcard.FieldTelephone), decoded.Values(vcard.FieldTelephone))
+	assert.Equal(t, card.Value(vcard.FieldNote), decoded.Value(vcard.FieldNote))
+
+	_, err = codec.Decode([]byte("BEGIN:VCARD"))
+	assert.Error(t, err)
+	_, err = codec.Decode([]byte("MECARD:;"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,59 @@
+package qrcodec
+
+import (
+	"errors"
+	"image"
+	"strings"
+
+	"github.com/emersion/go-vcard"
+	"github.com/makiuchi-d/gozxing"
+	gozxingqrcode "github.com/makiuchi-d/gozxing/qrcode"
+
+	mecardcodec "github.com/ulfschneider/qrvc/internal/adapters/codec/mecard"
+)
+
+// Decode locates the QR code in the image and reads the vCard or MeCard it carries.
+// Light QR codes on a dark background are found as well.
+func (qe *Codec) Decode(img image.Image) (vcard.Card, error) {
+	text, err := decodeQRCode(img, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(text, "MECARD:") {
+		meCardCodec := mecardcodec.NewCodec()
+		return meCardCodec.Decode([]byte(text))
+	}
+
+	if !strings.HasPrefix(strings.ToUpper(strings.TrimSpace(text)), "BEGIN:VCARD") {
+		return nil, errors.New("The QR code carries neither a vCard nor a MeCard")
+	}
+	return qe.cardCodec.Decode([]byte(text))
+}
+
+// decodeQRCode returns the text of the QR code in the image. An inverted QR code, light on dark, is only read when allowed.
+func decodeQRCode(img image.Image, allowInverted bool) (string, error) {
+	//go-qrcode writes UTF-8 without telling, which would otherwise be guessed as another charset
+	hints := map[gozxing.DecodeHintType]any{
+		gozxing.DecodeHintType_TRY_HARDER:    true,
+		gozxing.DecodeHintType_CHARACTER_SET: "UTF-8",
+	}
+
+	source := gozxing.NewLuminanceSourceFromImage(img)
+	reader := gozxingqrcode.NewQRCodeReader()
+	sources := []gozxing.LuminanceSource{source}
+	if allowInverted {
+		sources = append(sources, gozxing.NewInvertedLuminanceSource(source))
+	}
+	for _, luminance := range sources {
+		bitmap, err := gozxing.NewBinaryBitmap(gozxing.NewHybridBinarizer(luminance))
+		if err != nil {
+			return "", err
+		}
+		if result, err := reader.Decode(bitmap, hints); err == nil {
+			return result.GetText(), nil
+		}
+	}
+
+	return "", errors.New("No QR code found in the image")
+}
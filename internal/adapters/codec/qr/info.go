@@ -0,0 +1,65 @@
+package qrcodec
+
+import (
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+
+	"github.com/ulfschneider/qrvc/internal/application/config"
+	"github.com/ulfschneider/qrvc/internal/application/ports"
+)
+
+// formatInfoMask is applied to the format information of a QR code, to avoid a format information of all zeros.
+const formatInfoMask = 0x5412
+
+// symbolInfo describes the symbol of the QR code, the bitmap is the module matrix of the QR code.
+func symbolInfo(qr *qrcode.QRCode, bitmap [][]bool, settings config.QRCodeSettings) ports.QRCodeInfo {
+	quietZone := 0
+	if settings.Border {
+		quietZone = quietZoneModules
+	}
+
+	payload := config.PayloadVCard
+	if strings.HasPrefix(qr.Content, "MECARD:") {
+		payload = config.PayloadMeCard
+	}
+
+	return ports.QRCodeInfo{
+		Version:       qr.VersionNumber,
+		Modules:       len(bitmap) - 2*quietZone,
+		RecoveryLevel: config.RecoveryLevelName(qr.Level),
+		Mask:          maskPattern(bitmap, quietZone),
+		Payload:       payload,
+		PayloadBytes:  len(qr.Content),
+	}
+}
+
+// maskPattern reads the mask pattern from the format information of the symbol, which is placed around the top left finder pattern.
+// Bit i of the format information is at column 8 and row i for the bits 0 to 5, the bits 6 to 8 go around the corner
+// and the bits 9 to 14 are in row 8 at column 14-i.
+func maskPattern(bitmap [][]bool, quietZone int) int {
+	module := func(x, y int) bool {
+		return bitmap[quietZone+y][quietZone+x]
+	}
+
+	positions := [15][2]int{}
+	for i := 0; i <= 5; i++ {
+		positions[i] = [2]int{8, i}
+	}
+	positions[6] = [2]int{8, 7}
+	positions[7] = [2]int{8, 8}
+	positions[8] = [2]int{7, 8}
+	for i := 9; i <= 14; i++ {
+		positions[i] = [2]int{14 - i, 8}
+	}
+
+	format := 0
+	for i, position := range positions {
+		if module(position[0], position[1]) {
+			format |= 1 << i
+		}
+	}
+
+	//the 15 bits are 2 bits of the recovery level, 3 bits of the mask pattern and 10 bits of error correction
+	return ((format ^ formatInfoMask) >> 10) & 0x7
+}
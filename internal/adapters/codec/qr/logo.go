@@ -0,0 +1,149 @@
+package qrcodec
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/skip2/go-qrcode"
+
+	"github.com/ulfschneider/qrvc/internal/application/config"
+)
+
+// quietZoneModules is the width of the border that go-qrcode adds around the symbol.
+const quietZoneModules = 4
+
+// recoveryCapacities are the shares of the symbol that each recovery level is able to restore.
+var recoveryCapacities = map[qrcode.RecoveryLevel]float64{
+	qrcode.Low:     0.07,
+	qrcode.Medium:  0.15,
+	qrcode.High:    0.25,
+	qrcode.Highest: 0.30,
+}
+
+// logoRecoveryShare limits the modules covered by a logo to a part of the recovery capacity,
+// because covered modules damage more codewords than their count suggests and the rest is kept for real damage.
+const logoRecoveryShare = 0.5
+
+// LogoArea returns the modules of the bitmap that are reserved for the logo, or an empty rectangle when there is no logo.
+func LogoArea(bitmap [][]bool, settings config.QRCodeSettings) image.Rectangle {
+	if settings.Logo == nil {
+		return image.Rectangle{}
+	}
+	return symbolLogoArea(len(bitmap), settings)
+}
+
+func symbolLogoArea(modules int, settings config.QRCodeSettings) image.Rectangle {
+	quietZone := 0
+	if settings.Border {
+		quietZone = quietZoneModules
+	}
+
+	return logoArea(modules-2*quietZone, quietZone, settings.LogoSize)
+}
+
+// logoArea centers a square of modules with the given share of the symbol width, the square is kept on the module grid.
+func logoArea(symbolModules, quietZone int, logoSize float64) image.Rectangle {
+	box := int(math.Ceil(float64(symbolModules) * logoSize))
+	if (symbolModules-box)%2 != 0 {
+		box++
+	}
+	offset := quietZone + (symbolModules-box)/2
+	return image.Rect(offset, offset, offset+box, offset+box)
+}
+
+// fitLogoRecoveryLevel raises the recovery level of the QR code until it is able to restore the modules covered by the logo.
+// A raised recovery level is noted.
+func (qe *Codec) fitLogoRecoveryLevel(content string, qr *qrcode.QRCode, settings config.QRCodeSettings) (*qrcode.QRCode, string, error) {
+	initialLevel := qr.Level
+
+	for {
+		symbolModules := 17 + 4*qr.VersionNumber
+		area := logoArea(symbolModules, 0, settings.LogoSize)
+		covered := float64(area.Dx()*area.Dy()) / float64(symbolModules*symbolModules)
+
+		if covered <= recoveryCapacities[qr.Level]*logoRecoveryShare {
+			if qr.Level > initialLevel {
+				return qr, fmt.Sprintf("The recovery level has been raised to %s to keep the QR code readable with the logo", config.RecoveryLevelName(qr.Level)), nil
+			}
+			return qr, "", nil
+		}
+
+		if qr.Level == qrcode.Highest {
+			return nil, "", fmt.Errorf("The logo covers %.1f%% of the QR code, which is more than recovery level %s can recover. Use a smaller logo size.", covered*100, config.RecoveryLevelName(qr.Level))
+		}
+
+		next, err := qe.newQRCodeWithRecoveryLevel(content, qr.Level+1, settings)
+		if err != nil {
+			return nil, "", fmt.Errorf("The logo covers %.1f%% of the QR code, which is more than recovery level %s can recover, and a higher level is not possible: %w", covered*100, config.RecoveryLevelName(qr.Level), err)
+		}
+		qr = next
+	}
+}
+
+// drawLogo clears the logo area of the QR code image and draws the logo into it, keeping the aspect ratio of the logo.
+func drawLogo(img image.Image, modules int, area image.Rectangle, settings config.QRCodeSettings) image.Image {
+	bounds := img.Bounds()
+	result := image.NewRGBA(bounds)
+	draw.Draw(result, bounds, img, bounds.Min, draw.Src)
+
+	//a module starts at the first pixel that go-qrcode maps to it
+	pixelsPerModule := float64(bounds.Dx()) / float64(modules)
+	moduleStart := func(m int) int {
+		return int(math.Ceil(float64(m) * pixelsPerModule))
+	}
+	box := image.Rect(moduleStart(area.Min.X), moduleStart(area.Min.Y), moduleStart(area.Max.X), moduleStart(area.Max.Y)).Add(bounds.Min)
+
+	background := settings.BackgroundColor
+	if background == nil {
+		background = color.Transparent
+	}
+	draw.Draw(result, box, image.NewUniform(background), image.Point{}, draw.Src)
+
+	inset := int(math.Round(pixelsPerModule / 2))
+	inner := box.Inset(inset)
+	logoBounds := settings.Logo.Bounds()
+	if inner.Empty() || logoBounds.Empty() {
+		return result
+	}
+
+	scale := min(float64(inner.Dx())/float64(logoBounds.Dx()), float64(inner.Dy())/float64(logoBounds.Dy()))
+	width := max(1, int(math.Round(float64(logoBounds.Dx())*scale)))
+	height := max(1, int(math.Round(float64(logoBounds.Dy())*scale)))
+	target := image.Rect(0, 0, width, height).Add(image.Pt(inner.Min.X+(inner.Dx()-width)/2, inner.Min.Y+(inner.Dy()-height)/2))
+
+	draw.Draw(result, target, scaleImage(settings.Logo, width, height), image.Point{}, draw.Over)
+
+	return result
+}
+
+// scaleImage resizes the image by averaging all source pixels that fall into a target pixel.
+func scaleImage(src image.Image, width, height int) image.Image {
+	srcBounds := src.Bounds()
+	dst := image.NewRGBA64(image.Rect(0, 0, width, height))
+
+	scaleX := float64(srcBounds.Dx()) / float64(width)
+	scaleY := float64(srcBounds.Dy()) / float64(height)
+
+	for y := 0; y < height; y++ {
+		y0 := srcBounds.Min.Y + int(float64(y)*scaleY)
+		y1 := max(y0+1, srcBounds.Min.Y+int(float64(y+1)*scaleY))
+		for x := 0; x < width; x++ {
+			x0 := srcBounds.Min.X + int(float64(x)*scaleX)
+			x1 := max(x0+1, srcBounds.Min.X+int(float64(x+1)*scaleX))
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,77 @@
+package qrcodec
+
+import (
+	"fmt"
+
+	"github.com/emersion/go-vcard"
+	"github.com/skip2/go-qrcode"
+
+	mecardcodec "github.com/ulfschneider/qrvc/internal/adapters/codec/mecard"
+	"github.com/ulfschneider/qrvc/internal/application/config"
+)
+
+// payload is the content of a QR code, together with the card it has been made of.
+type payload struct {
+	text   string
+	card   vcard.Card
+	meCard bool
+	//vCardVersion is the QR code version the vCard would need, when the MeCard has been picked because it is smaller
+	vCardVersion int
+}
+
+// payload returns the content of the QR code, which is the vCard or the shorter MeCard of the card.
+func (qe *Codec) payload(card vcard.Card, settings config.QRCodeSettings) (payload, error) {
+	//the vCard codec that has read the card writes its unchanged fields as they have been read, and keeps the empty fields as the vCard file
+	vCardContent, err := qe.cardCodec.Encode(card)
+	if err != nil {
+		return payload{}, err
+	}
+
+	if settings.Payload != config.PayloadMeCard && settings.Payload != config.PayloadAuto {
+		return payload{text: string(vCardContent), card: card}, nil
+	}
+
+	meCardCodec := mecardcodec.NewCodec()
+	meCardContent, err := meCardCodec.Encode(card)
+	if err != nil {
+		return payload{}, err
+	}
+
+	meCard := payload{text: string(meCardContent), card: card, meCard: true}
+	if settings.Payload == config.PayloadAuto {
+		vCardVersion, meCardVersion := qrCodeVersion(string(vCardContent), settings), qrCodeVersion(meCard.text, settings)
+		if meCardVersion >= vCardVersion {
+			return payload{text: string(vCardContent), card: card}, nil
+		}
+		meCard.vCardVersion = vCardVersion
+	}
+
+	return meCard, nil
+}
+
+// payloadNotes tell when the QR code carries a MeCard, and which fields are not part of it.
+func payloadNotes(content payload, qr *qrcode.QRCode) []string {
+	if !content.meCard {
+		return nil
+	}
+	notes := []string{}
+	if content.vCardVersion > 0 {
+		notes = append(notes, fmt.Sprintf("The QR code carries a MeCard, which needs QR code version %d instead of version %d for the vCard", qr.VersionNumber, content.vCardVersion))
+	}
+
+	meCardCodec := mecardcodec.NewCodec()
+	for _, field := range meCardCodec.Unsupported(content.card) {
+		notes = append(notes, fmt.Sprintf("The field %s can not be carried by a MeCard and is left out of the QR code", field))
+	}
+	return notes
+}
+
+// qrCodeVersion returns the QR code version that the content needs at the recovery level of the settings,
+// or a version above the maximum when the content does not fit at all.
+func qrCodeVersion(content string, settings config.QRCodeSettings) int {
+	qr, err := qrcode.New(content, settings.RecoveryLevel)
+	if err != nil {
+		return config.MaxQRCodeVersion + 1
+	}
+	return qr.VersionNumber
+}
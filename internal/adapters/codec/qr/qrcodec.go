@@ -1,40 +1,169 @@
 package qrcodec
 
 import (
+	"fmt"
 	"image"
 
 	"github.com/emersion/go-vcard"
 	"github.com/skip2/go-qrcode"
 
-	vcardcodec "github.com/ulfschneider/qrvc/internal/adapters/codec/vcard"
+	notifiercli "github.com/ulfschneider/qrvc/internal/adapters/notifier/cli"
 	"github.com/ulfschneider/qrvc/internal/application/config"
+	"github.com/ulfschneider/qrvc/internal/application/ports"
 )
 
-func NewCodec() Codec {
-	return Codec{}
+// NewCodec creates the QR codec, which reads the vCards of QR codes with the given vCard codec.
+func NewCodec(cardCodec ports.VCardCodec) Codec {
+	return Codec{cardCodec: cardCodec, userNotifier: notifiercli.NewUserNotifier()}
 }
 
 type Codec struct {
+	cardCodec    ports.VCardCodec
+	userNotifier notifiercli.UserNotifier
 }
 
-func (qe *Codec) Encode(card vcard.Card, settings config.QRCodeSettings) (image.Image, error) {
-	cardCodec := vcardcodec.NewCodec()
+// Encode returns the image of the QR code of the card, together with the description of the symbol in the image.
+func (qe *Codec) Encode(card vcard.Card, settings config.QRCodeSettings) (image.Image, ports.QRCodeInfo, error) {
+	qr, notes, err := qe.newQRCode(card, settings)
+	if err != nil {
+		return nil, ports.QRCodeInfo{}, err
+	}
+
+	//the image encodes the same symbol again, which gives the same modules as the bitmap
+	bitmap := qr.Bitmap()
+	img := qr.Image(settings.Size)
+
+	if settings.Logo != nil {
+		modules := len(bitmap)
+		img = drawLogo(img, modules, symbolLogoArea(modules, settings), settings)
+	}
+
+	if settings.Verify {
+		if err := qe.verify(img, qr.Content, settings); err != nil {
+			return nil, ports.QRCodeInfo{}, err
+		}
+	}
+
+	info := symbolInfo(qr, bitmap, settings)
+	info.Notes = notes
+	return img, info, nil
+}
+
+// Bitmap returns the module matrix of the QR code, bitmap[y][x] is true for a dark module, together with the description of the symbol.
+// The matrix includes the quiet zone when the settings ask for a border.
+func (qe *Codec) Bitmap(card vcard.Card, settings config.QRCodeSettings) ([][]bool, ports.QRCodeInfo, error) {
+	qr, notes, err := qe.newQRCode(card, settings)
+	if err != nil {
+		return nil, ports.QRCodeInfo{}, err
+	}
+
+	bitmap := qr.Bitmap()
+	if settings.Verify {
+		img := drawBitmap(bitmap, settings)
+		if settings.Logo != nil {
+			//the SVG and PDF outputs cover the same modules with the logo, which is read back with the logo as well
+			logoSettings := settings
+			logoSettings.BackgroundColor = opaque(settings.BackgroundColor)
+			img = drawLogo(img, len(bitmap), symbolLogoArea(len(bitmap), settings), logoSettings)
+		}
+		if err := qe.verify(img, qr.Content, settings); err != nil {
+			return nil, ports.QRCodeInfo{}, err
+		}
+	}
+
+	info := symbolInfo(qr, bitmap, settings)
+	info.Notes = notes
+	return bitmap, info, nil
+}
+
+// Fits tells whether the card fits into the maximum version without leaving out fields, at the lowest recovery level the settings allow.
+// Nothing is reported to the user, the card is only measured.
+func (qe *Codec) Fits(card vcard.Card, settings config.QRCodeSettings) bool {
+	content, err := qe.payload(card, settings)
+	if err != nil {
+		return false
+	}
+	level := settings.RecoveryLevel
+	if settings.AutoRecoveryLevel {
+		level = qrcode.Low
+	}
+	qr, err := qrcode.New(content.text, level)
+	return err == nil && qr.VersionNumber <= qe.maxVersion(settings)
+}
 
-	vCardContent, err := cardCodec.Encode(card)
+// newQRCode creates the QR code of the card, together with the notes on what has been changed to make the card fit.
+// The notes are returned with the description of the QR code instead of being shown, because the editor preview encodes the card on every change.
+func (qe *Codec) newQRCode(card vcard.Card, settings config.QRCodeSettings) (*qrcode.QRCode, []string, error) {
+	content, err := qe.payload(card, settings)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	qr, err := qrcode.New(string(vCardContent), settings.RecoveryLevel)
+	qr, notes, err := qe.newQRCodeWithLevel(content.text, settings)
+	if err != nil && len(settings.TrimOrder) > 0 {
+		qr, content, notes, err = qe.trimToFit(card, settings, err)
+	}
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	notes = append(notes, payloadNotes(content, qr)...)
+
+	if settings.Logo != nil {
+		var note string
+		if qr, note, err = qe.fitLogoRecoveryLevel(content.text, qr, settings); err != nil {
+			return nil, nil, err
+		}
+		if note != "" {
+			notes = append(notes, note)
+		}
 	}
 
 	qr.DisableBorder = !settings.Border
 	qr.ForegroundColor = settings.ForegroundColor
 	qr.BackgroundColor = settings.BackgroundColor
 
-	img := qr.Image(settings.Size)
+	return qr, notes, nil
+}
+
+// newQRCodeWithLevel creates the QR code with the recovery level of the settings, or with the highest level that fits when the level is picked automatically.
+// A lowered recovery level is noted.
+func (qe *Codec) newQRCodeWithLevel(content string, settings config.QRCodeSettings) (*qrcode.QRCode, []string, error) {
+	if settings.AutoRecoveryLevel {
+		return qe.newQRCodeWithAutoRecoveryLevel(content, settings)
+	}
+	qr, err := qe.newQRCodeWithRecoveryLevel(content, settings.RecoveryLevel, settings)
+	return qr, nil, err
+}
+
+func (qe *Codec) newQRCodeWithRecoveryLevel(content string, level qrcode.RecoveryLevel, settings config.QRCodeSettings) (*qrcode.QRCode, error) {
+	qr, err := qrcode.New(content, level)
+	if err != nil {
+		//the content exceeds even the largest version of the standard
+		return nil, fmt.Errorf("The card does not fit into QR code version %d with recovery level %s", config.MaxQRCodeVersion, config.RecoveryLevelName(level))
+	}
+
+	if maxVersion := qe.maxVersion(settings); qr.VersionNumber > maxVersion {
+		return nil, fmt.Errorf("The card needs QR code version %d with recovery level %s, which exceeds the maximum version %d", qr.VersionNumber, config.RecoveryLevelName(level), maxVersion)
+	}
+
+	return qr, nil
+}
+
+// newQRCodeWithAutoRecoveryLevel picks the highest recovery level, starting at settings.RecoveryLevel, that keeps the QR code within the maximum version.
+func (qe *Codec) newQRCodeWithAutoRecoveryLevel(content string, settings config.QRCodeSettings) (*qrcode.QRCode, []string, error) {
+	var err error
+	for level := settings.RecoveryLevel; level >= qrcode.Low; level-- {
+		var qr *qrcode.QRCode
+		if qr, err = qe.newQRCodeWithRecoveryLevel(content, level, settings); err == nil {
+			if level < settings.RecoveryLevel {
+				return qr, []string{fmt.Sprintf("The recovery level has been lowered to %s to fit the vCard into QR code version %d", config.RecoveryLevelName(level), qe.maxVersion(settings))}, nil
+			}
+			return qr, nil, nil
+		}
+	}
+	return nil, nil, err
+}
 
-	return img, nil
+func (qe *Codec) maxVersion(settings config.QRCodeSettings) int {
+	return config.EffectiveMaxVersion(settings)
 }
@@ -1,13 +1,19 @@
 package qrcodec_test
 
 import (
+	"fmt"
 	"image"
+	"image/color"
+	"image/draw"
+	"strings"
 	"testing"
 
+	"github.com/emersion/go-vcard"
 	"github.com/mazznoer/csscolorparser"
 	"github.com/skip2/go-qrcode"
 	"github.com/stretchr/testify/assert"
 
+	mecardcodec "github.com/ulfschneider/qrvc/internal/adapters/codec/mecard"
 	qrcodec "github.com/ulfschneider/qrvc/internal/adapters/codec/qr"
 	vcardcodec "github.com/ulfschneider/qrvc/internal/adapters/codec/vcard"
 	testutil "github.com/ulfschneider/qrvc/internal/test/util"
@@ -17,7 +23,7 @@ import (
 
 func TestQRCodec(t *testing.T) {
 	card := testutil.CreateCard()
-	cardCodec := vcardcodec.NewCodec()
+	cardCodec := vcardcodec.NewCodec(config.Settings{})
 	vcf, _ := cardCodec.Encode(card)
 
 	backgroundColor, _ := csscolorparser.Parse("transparent")
@@ -27,8 +33,8 @@ func TestQRCodec(t *testing.T) {
 	expectedImg, err := makeQRCode(vcf, testSettings)
 	assert.NoError(t, err)
 
-	qrCodec := qrcodec.NewCodec()
-	resultImg, _ := qrCodec.Encode(card, testSettings)
+	qrCodec := testutil.CreateQRCodec()
+	resultImg, _, _ := qrCodec.Encode(card, testSettings)
 
 	assert.Equal(t, testutil.ToRGBA(expectedImg).Pix, testutil.ToRGBA(resultImg).Pix)
 }
@@ -47,3 +53,386 @@ func makeQRCode(vcf []byte, settings config.QRCodeSettings) (image.Image, error)
 
 	return img, nil
 }
+
+func TestQRCodecBitmap(t *testing.T) {
+	card := testutil.CreateCard()
+	cardCodec := vcardcodec.NewCodec(config.Settings{})
+	vcf, _ := cardCodec.Encode(card)
+
+	testSettings := testutil.LoadTestSettings().App.QRSettings
+
+	for _, border := range []bool{false, true} {
+		testSettings.Border = border
+
+		q, err := qrcode.New(string(vcf), testSettings.RecoveryLevel)
+		assert.NoError(t, err)
+		q.DisableBorder = !border
+
+		qrCodec := testutil.CreateQRCodec()
+		bitmap, _, err := qrCodec.Bitmap(card, testSettings)
+		assert.NoError(t, err)
+		assert.Equal(t, q.Bitmap(), bitmap)
+	}
+}
+
+func TestQRCodecAutoRecoveryLevel(t *testing.T) {
+	card := testutil.CreateCard()
+	cardCodec := vcardcodec.NewCodec(config.Settings{})
+	vcf, _ := cardCodec.Encode(card)
+
+	testSettings := testutil.LoadTestSettings().App.QRSettings
+	testSettings.AutoRecoveryLevel = true
+	testSettings.RecoveryLevel = qrcode.Highest
+
+	lowQR, err := qrcode.New(string(vcf), qrcode.Low)
+	assert.NoError(t, err)
+	highestQR, err := qrcode.New(string(vcf), qrcode.Highest)
+	assert.NoError(t, err)
+	assert.Greater(t, highestQR.VersionNumber, lowQR.VersionNumber)
+	lowQR.DisableBorder = !testSettings.Border
+	highestQR.DisableBorder = !testSettings.Border
+
+	qrCodec := testutil.CreateQRCodec()
+
+	//without a version limit the highest level is used
+	bitmap, info, err := qrCodec.Bitmap(card, testSettings)
+	assert.NoError(t, err)
+	assert.Equal(t, highestQR.Bitmap(), bitmap)
+	assert.Empty(t, info.Notes)
+
+	//the level is lowered to fit into the maximum version, which is noted
+	testSettings.MaxVersion = lowQR.VersionNumber
+	bitmap, info, err = qrCodec.Bitmap(card, testSettings)
+	assert.NoError(t, err)
+	assert.Len(t, bitmap, len(lowQR.Bitmap()))
+	assert.Equal(t, []string{fmt.Sprintf("The recovery level has been lowered to low to fit the vCard into QR code version %d", lowQR.VersionNumber)}, info.Notes)
+
+	//nothing fits without leaving out fields
+	testSettings.TrimOrder = nil
+	testSettings.MaxVersion = lowQR.VersionNumber - 1
+	_, _, err = qrCodec.Bitmap(card, testSettings)
+	assert.Error(t, err)
+
+	//a fixed level is not lowered
+	testSettings.AutoRecoveryLevel = false
+	testSettings.MaxVersion = lowQR.VersionNumber
+	_, _, err = qrCodec.Bitmap(card, testSettings)
+	assert.Error(t, err)
+
+	//without a maximum version the level is never raised beyond the largest version of the standard,
+	//which carries 2953 bytes with recovery level low
+	card.SetValue(vcard.FieldNote, strings.Repeat("x", 3000))
+	testSettings.AutoRecoveryLevel = true
+	testSettings.MaxVersion = 0
+	_, _, err = qrCodec.Bitmap(card, testSettings)
+	assert.EqualError(t, err, "The card does not fit into QR code version 40 with recovery level low")
+}
+
+func TestQRCodecFits(t *testing.T) {
+	card := testutil.CreateCard()
+	vcf := testutil.EncodeCard(card)
+	lowQR, err := qrcode.New(string(vcf), qrcode.Low)
+	assert.NoError(t, err)
+
+	testSettings := testutil.LoadTestSettings().App.QRSettings
+	testSettings.RecoveryLevel = qrcode.Highest
+	testSettings.MaxVersion = lowQR.VersionNumber
+	qrCodec := testutil.CreateQRCodec()
+
+	//an automatic level is lowered to fit, a fixed level is not
+	testSettings.AutoRecoveryLevel = true
+	assert.True(t, qrCodec.Fits(card, testSettings))
+	testSettings.AutoRecoveryLevel = false
+	assert.False(t, qrCodec.Fits(card, testSettings))
+
+	//the trim order is not applied to measure the card
+	card.SetValue(vcard.FieldPhoto, "data:image/png;base64,"+strings.Repeat("a", 3000))
+	testSettings.AutoRecoveryLevel = true
+	testSettings.MaxVersion = 0
+	assert.False(t, qrCodec.Fits(card, testSettings))
+}
+
+func TestQRCodecTrimOrder(t *testing.T) {
+	card := testutil.CreateCard()
+	vcf := testutil.EncodeCard(card)
+
+	testSettings := testutil.LoadTestSettings().App.QRSettings
+	fullQR, err := qrcode.New(string(vcf), testSettings.RecoveryLevel)
+	assert.NoError(t, err)
+
+	//the post office box and the extended address are left out to fit into the smaller version
+	trimmed := testutil.CreateCard()
+	address := trimmed.Address()
+	address.PostOfficeBox = ""
+	address.ExtendedAddress = ""
+	trimmed.SetAddress(address)
+	trimmedQR, err := qrcode.New(string(testutil.EncodeCard(trimmed)), testSettings.RecoveryLevel)
+	assert.NoError(t, err)
+	assert.Less(t, trimmedQR.VersionNumber, fullQR.VersionNumber)
+	trimmedQR.DisableBorder = !testSettings.Border
+
+	qrCodec := testutil.CreateQRCodec()
+	testSettings.MaxVersion = trimmedQR.VersionNumber
+	testSettings.TrimOrder = []string{"NOTE", "ADR.pobox", "ADR.extended", "TITLE"}
+	bitmap, info, err := qrCodec.Bitmap(card, testSettings)
+	assert.NoError(t, err)
+	assert.Equal(t, trimmedQR.Bitmap(), bitmap)
+	assert.Equal(t, []string{fmt.Sprintf("The card does not fit into QR code version %d, therefore ADR.pobox, ADR.extended have been left out of the QR code", trimmedQR.VersionNumber)}, info.Notes)
+
+	//the card itself is untouched
+	assert.Equal(t, testutil.CreateCard(), card)
+
+	//the card does not fit even without the fields
+	testSettings.MaxVersion = 1
+	_, _, err = qrCodec.Bitmap(card, testSettings)
+	assert.ErrorContains(t, err, "even without ADR.pobox, ADR.extended, TITLE")
+
+	//components that are empty already are not reported as left out
+	card = vcard.Card{}
+	card.SetValue(vcard.FieldVersion, "3.0")
+	card.SetValue(vcard.FieldFormattedName, "Dr. John Doe")
+	card.SetValue(vcard.FieldName, "Doe;John;;Dr.;")
+	card.SetValue(vcard.FieldAddress, ";;Main Street 1;Berlin;;10115;Germany")
+	testSettings.TrimOrder = []string{"ADR.pobox", "ADR.extended", "N.additional", "N.prefix"}
+	_, _, err = qrCodec.Bitmap(card, testSettings)
+	assert.ErrorContains(t, err, "even without N.prefix")
+	assert.NotContains(t, err.Error(), "ADR.pobox")
+	assert.NotContains(t, err.Error(), "N.additional")
+}
+
+func createTestLogo() image.Image {
+	logo := image.NewRGBA(image.Rect(0, 0, 60, 30))
+	draw.Draw(logo, logo.Bounds(), image.NewUniform(color.RGBA{R: 200, A: 255}), image.Point{}, draw.Src)
+	return logo
+}
+
+func TestQRCodecLogo(t *testing.T) {
+	card := testutil.CreateCard()
+	cardCodec := vcardcodec.NewCodec(config.Settings{})
+	vcf, _ := cardCodec.Encode(card)
+
+	testSettings := testutil.LoadTestSettings().App.QRSettings
+	testSettings.Logo = createTestLogo()
+	testSettings.LogoSize = 0.2
+
+	qrCodec := testutil.CreateQRCodec()
+
+	//the recovery level is raised to protect the modules under the logo
+	bitmap, info, err := qrCodec.Bitmap(card, testSettings)
+	assert.NoError(t, err)
+	assert.Len(t, info.Notes, 1)
+	assert.Contains(t, info.Notes[0], "The recovery level has been raised")
+	lowQR, err := qrcode.New(string(vcf), qrcode.Low)
+	assert.NoError(t, err)
+	lowQR.DisableBorder = !testSettings.Border
+	assert.NotEqual(t, lowQR.Bitmap(), bitmap)
+
+	area := qrcodec.LogoArea(bitmap, testSettings)
+	assert.False(t, area.Empty())
+	assert.Equal(t, len(bitmap)-area.Max.X, area.Min.X)
+
+	//the logo is drawn into the center of the image
+	img, _, err := qrCodec.Encode(card, testSettings)
+	assert.NoError(t, err)
+	center := img.Bounds().Max.Div(2)
+	assert.Equal(t, color.RGBAModel.Convert(color.RGBA{R: 200, A: 255}), color.RGBAModel.Convert(img.At(center.X, center.Y)))
+
+	//a logo that is too large is refused
+	testSettings.LogoSize = 0.5
+	_, _, err = qrCodec.Encode(card, testSettings)
+	assert.Error(t, err)
+
+	//without logo there is no logo area
+	testSettings.Logo = nil
+	assert.True(t, qrcodec.LogoArea(bitmap, testSettings).Empty())
+}
+
+func TestQRCodecPayload(t *testing.T) {
+	card := testutil.CreateCard()
+	meCardCodec := mecardcodec.NewCodec()
+	mecard, _ := meCardCodec.Encode(card)
+
+	testSettings := testutil.LoadTestSettings().App.QRSettings
+	meCardQR, err := qrcode.New(string(mecard), testSettings.RecoveryLevel)
+	assert.NoError(t, err)
+	meCardQR.DisableBorder = !testSettings.Border
+
+	qrCodec := testutil.CreateQRCodec()
+
+	testSettings.Payload = config.PayloadMeCard
+	bitmap, _, err := qrCodec.Bitmap(card, testSettings)
+	assert.NoError(t, err)
+	assert.Equal(t, meCardQR.Bitmap(), bitmap)
+
+	//the MeCard gives the smaller QR code, which is noted together with the fields the MeCard leaves out
+	testSettings.Payload = config.PayloadAuto
+	bitmap, info, err := qrCodec.Bitmap(card, testSettings)
+	assert.NoError(t, err)
+	assert.Equal(t, meCardQR.Bitmap(), bitmap)
+	assert.Contains(t, info.Notes[0], "The QR code carries a MeCard")
+	assert.Contains(t, info.Notes, "The field GENDER can not be carried by a MeCard and is left out of the QR code")
+
+	//the MeCard needs a smaller QR code than the vCard
+	testSettings.Payload = config.PayloadVCard
+	bitmap, info, err = qrCodec.Bitmap(card, testSettings)
+	assert.NoError(t, err)
+	assert.Greater(t, len(bitmap), len(meCardQR.Bitmap()))
+	assert.Empty(t, info.Notes)
+
+	//fields without value are only part of the QR code when the vCard codec keeps them
+	card.SetValue(vcard.FieldNote, "")
+	_, withoutEmpty, err := qrCodec.Bitmap(card, testSettings)
+	assert.NoError(t, err)
+	skeletonCodec := vcardcodec.NewCodec(config.Settings{KeepEmptyFields: true})
+	skeletonQRCodec := qrcodec.NewCodec(&skeletonCodec)
+	_, withEmpty, err := skeletonQRCodec.Bitmap(card, testSettings)
+	assert.NoError(t, err)
+	assert.Greater(t, withEmpty.PayloadBytes, withoutEmpty.PayloadBytes)
+}
+
+func TestQRCodecPayloadLines(t *testing.T) {
+	cardCodec := vcardcodec.NewCodec(config.Settings{})
+	qrCodec := qrcodec.NewCodec(&cardCodec)
+	testSettings := testutil.LoadTestSettings().App.QRSettings
+	testSettings.AutoRecoveryLevel = false
+
+	//the QR code carries the lines of the card as they have been read by the codec that is given to the QR codec
+	vcf := testutil.GoldenVCards()["ios.vcf"]
+	card, err := cardCodec.Decode(vcf)
+	assert.NoError(t, err)
+	bitmap, _, err := qrCodec.Bitmap(card, testSettings)
+	assert.NoError(t, err)
+
+	q, err := qrcode.New(string(vcf), testSettings.RecoveryLevel)
+	assert.NoError(t, err)
+	q.DisableBorder = !testSettings.Border
+	assert.Equal(t, q.Bitmap(), bitmap)
+}
+
+func TestQRCodecInfo(t *testing.T) {
+	card := testutil.CreateCard()
+	vcf := testutil.EncodeCard(card)
+
+	qrCodec := testutil.CreateQRCodec()
+	masks := []int{}
+	for _, border := range []bool{false, true} {
+		testSettings := testutil.LoadTestSettings().App.QRSettings
+		testSettings.Border = border
+		testSettings.RecoveryLevel = qrcode.Medium
+
+		q, err := qrcode.New(string(vcf), testSettings.RecoveryLevel)
+		assert.NoError(t, err)
+
+		_, info, err := qrCodec.Bitmap(card, testSettings)
+		assert.NoError(t, err)
+		assert.Equal(t, q.VersionNumber, info.Version)
+		assert.Equal(t, 17+4*q.VersionNumber, info.Modules)
+		assert.Equal(t, "medium", info.RecoveryLevel)
+		assert.GreaterOrEqual(t, info.Mask, 0)
+		assert.LessOrEqual(t, info.Mask, 7)
+		assert.Equal(t, config.PayloadVCard, info.Payload)
+		assert.Equal(t, len(vcf), info.PayloadBytes)
+		masks = append(masks, info.Mask)
+
+		//the image is described like the bitmap
+		_, imageInfo, err := qrCodec.Encode(card, testSettings)
+		assert.NoError(t, err)
+		assert.Equal(t, info, imageInfo)
+	}
+
+	//the quiet zone does not change the mask pattern
+	assert.Equal(t, masks[0], masks[1])
+
+	//the info describes the QR code with the logo
+	testSettings := testutil.LoadTestSettings().App.QRSettings
+	testSettings.Logo = createTestLogo()
+	_, info, err := qrCodec.Encode(card, testSettings)
+	assert.NoError(t, err)
+	assert.NotEqual(t, config.RecoveryLevelName(testSettings.RecoveryLevel), info.RecoveryLevel)
+}
+
+func TestQRCodecDecode(t *testing.T) {
+	card := testutil.CreateConformingCard()
+	testSettings := testutil.LoadTestSettings().App.QRSettings
+	qrCodec := testutil.CreateQRCodec()
+
+	img, _, err := qrCodec.Encode(card, testSettings)
+	assert.NoError(t, err)
+	decoded, err := qrCodec.Decode(img)
+	assert.NoError(t, err)
+	assert.Equal(t, card, decoded)
+
+	//light modules on a dark background
+	testSettings.ForegroundColor = color.White
+	testSettings.BackgroundColor = color.Black
+	img, _, err = qrCodec.Encode(card, testSettings)
+	assert.NoError(t, err)
+	decoded, err = qrCodec.Decode(img)
+	assert.NoError(t, err)
+	assert.Equal(t, card, decoded)
+
+	//a MeCard
+	testSettings = testutil.LoadTestSettings().App.QRSettings
+	testSettings.Payload = config.PayloadMeCard
+	img, _, err = qrCodec.Encode(card, testSettings)
+	assert.NoError(t, err)
+	decoded, err = qrCodec.Decode(img)
+	assert.NoError(t, err)
+	assert.Equal(t, "Family name", decoded.Name().FamilyName)
+	assert.Equal(t, []string{"Cell phone", "Work phone", "Home phone"}, decoded.Values("TEL"))
+
+	//an image without QR code
+	_, err = qrCodec.Decode(createTestLogo())
+	assert.ErrorContains(t, err, "No QR code found")
+
+	//a QR code without a card
+	q, err := qrcode.New("https://example.com", qrcode.Medium)
+	assert.NoError(t, err)
+	_, err = qrCodec.Decode(q.Image(300))
+	assert.ErrorContains(t, err, "neither a vCard nor a MeCard")
+}
+
+func TestQRCodecVerify(t *testing.T) {
+	card := testutil.CreateCard()
+	qrCodec := testutil.CreateQRCodec()
+
+	testSettings := testutil.LoadTestSettings().App.QRSettings
+	testSettings.Verify = true
+	testSettings.MinContrast = 3
+
+	for _, border := range []bool{false, true} {
+		testSettings.Border = border
+		_, _, err := qrCodec.Encode(card, testSettings)
+		assert.NoError(t, err)
+		_, _, err = qrCodec.Bitmap(card, testSettings)
+		assert.NoError(t, err)
+	}
+
+	//the logo covers modules, which are restored by the recovery level
+	testSettings.Logo = createTestLogo()
+	testSettings.LogoSize = 0.2
+	_, _, err := qrCodec.Encode(card, testSettings)
+	assert.NoError(t, err)
+	_, _, err = qrCodec.Bitmap(card, testSettings)
+	assert.NoError(t, err)
+	testSettings.Logo = nil
+
+	//a transparent background counts as white
+	testSettings.BackgroundColor = color.Transparent
+	_, _, err = qrCodec.Encode(card, testSettings)
+	assert.NoError(t, err)
+
+	//light on dark
+	testSettings.ForegroundColor = color.White
+	testSettings.BackgroundColor = color.Black
+	_, _, err = qrCodec.Encode(card, testSettings)
+	assert.ErrorContains(t, err, "lighter than the background")
+	_, _, err = qrCodec.Bitmap(card, testSettings)
+	assert.ErrorContains(t, err, "lighter than the background")
+
+	//too little contrast
+	testSettings.ForegroundColor = color.RGBA{R: 0xaa, G: 0xaa, B: 0xaa, A: 0xff}
+	testSettings.BackgroundColor = color.White
+	_, _, err = qrCodec.Encode(card, testSettings)
+	assert.ErrorContains(t, err, "contrast ratio of the foreground and background colors is 2.3:1")
+}
@@ -0,0 +1,83 @@
+package qrcodec
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/emersion/go-vcard"
+	"github.com/skip2/go-qrcode"
+
+	"github.com/ulfschneider/qrvc/internal/application/config"
+	qrcard "github.com/ulfschneider/qrvc/internal/domain"
+)
+
+// trimToFit leaves the fields of the trim order out of a copy of the card, one after the other, until the QR code fits into the maximum version.
+// The card itself stays untouched, because the full card is still written as vCard file. The fields that have been left out are noted.
+func (qe *Codec) trimToFit(card vcard.Card, settings config.QRCodeSettings, fitErr error) (*qrcode.QRCode, payload, []string, error) {
+	trimmed := qrcard.CopyCard(card)
+	omitted := []string{}
+
+	for _, item := range settings.TrimOrder {
+		if !trim(trimmed, item) {
+			continue
+		}
+		omitted = append(omitted, item)
+
+		content, err := qe.payload(trimmed, settings)
+		if err != nil {
+			return nil, payload{}, nil, err
+		}
+		var qr *qrcode.QRCode
+		var notes []string
+		if qr, notes, fitErr = qe.newQRCodeWithLevel(content.text, settings); fitErr == nil {
+			notes = append(notes, fmt.Sprintf("The card does not fit into QR code version %d, therefore %s have been left out of the QR code", qe.maxVersion(settings), strings.Join(omitted, ", ")))
+			return qr, content, notes, nil
+		}
+	}
+
+	if len(omitted) == 0 {
+		return nil, payload{}, nil, fitErr
+	}
+	return nil, payload{}, nil, fmt.Errorf("%w, even without %s", fitErr, strings.Join(omitted, ", "))
+}
+
+// trim leaves a field, or a part of a field like ADR.pobox, out of the card and tells whether the card has changed.
+func trim(card vcard.Card, item string) bool {
+	key, component, isComponent := strings.Cut(item, ".")
+
+	if !isComponent {
+		for _, field := range card[key] {
+			if strings.Trim(field.Value, "; ") != "" {
+				delete(card, key)
+				return true
+			}
+		}
+		return false
+	}
+
+	index, ok := config.TrimComponents[key][component]
+	if !ok {
+		return false
+	}
+
+	changed := false
+	for _, field := range card[key] {
+		parts := strings.Split(field.Value, ";")
+		if key == vcard.FieldOrganization {
+			//the units of an organization are a list that is left out as a whole
+			if index >= len(parts) || strings.Join(parts[index:], "") == "" {
+				continue
+			}
+			parts = parts[:index]
+		} else {
+			//a component that is empty already is not left out
+			if index >= len(parts) || parts[index] == "" {
+				continue
+			}
+			parts[index] = ""
+		}
+		field.Value = strings.Join(parts, ";")
+		changed = true
+	}
+	return changed
+}
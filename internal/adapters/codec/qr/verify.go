@@ -0,0 +1,93 @@
+package qrcodec
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/ulfschneider/qrvc/internal/application/config"
+)
+
+// verifyModulePixels is the width of a module when a bitmap is drawn to be read back.
+const verifyModulePixels = 4
+
+// verify reads the QR code image back and compares the payload byte for byte with the content that has been encoded.
+// It fails when the colors have too little contrast, or when the foreground is lighter than the background,
+// because most QR code readers expect dark modules on a light background.
+func (qe *Codec) verify(img image.Image, content string, settings config.QRCodeSettings) error {
+	foreground, background := luminance(settings.ForegroundColor), luminance(settings.BackgroundColor)
+	if foreground > background {
+		return errors.New("The foreground color is lighter than the background color, which most QR code readers can not read. Use a dark foreground on a light background.")
+	}
+	ratio := (background + 0.05) / (foreground + 0.05)
+	if ratio < settings.MinContrast {
+		return fmt.Errorf("The contrast ratio of the foreground and background colors is %.1f:1, which is below the minimum of %.1f:1", ratio, settings.MinContrast)
+	}
+
+	//the quiet zone is added when the image has none, because the layout is not part of the verification
+	text, err := decodeQRCode(withQuietZone(img, settings), false)
+	if err != nil {
+		return fmt.Errorf("The QR code can not be read back: %w", err)
+	}
+	if text != content {
+		return errors.New("The QR code reads back differently from the content that has been encoded")
+	}
+
+	qe.userNotifier.Notifyf("The QR code has been read back and verified, the contrast ratio is %s", fmt.Sprintf("%.1f:1", ratio))
+	return nil
+}
+
+// drawBitmap draws the modules of the bitmap with the colors of the settings.
+func drawBitmap(bitmap [][]bool, settings config.QRCodeSettings) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, len(bitmap)*verifyModulePixels, len(bitmap)*verifyModulePixels))
+	draw.Draw(img, img.Bounds(), image.NewUniform(opaque(settings.BackgroundColor)), image.Point{}, draw.Src)
+
+	foreground := image.NewUniform(opaque(settings.ForegroundColor))
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				module := image.Rect(x, y, x+1, y+1)
+				draw.Draw(img, image.Rectangle{Min: module.Min.Mul(verifyModulePixels), Max: module.Max.Mul(verifyModulePixels)}, foreground, image.Point{}, draw.Src)
+			}
+		}
+	}
+	return img
+}
+
+func withQuietZone(img image.Image, settings config.QRCodeSettings) image.Image {
+	if settings.Border {
+		return img
+	}
+
+	bounds := img.Bounds()
+	margin := bounds.Dx() / 8
+	padded := image.NewRGBA(image.Rect(0, 0, bounds.Dx()+2*margin, bounds.Dy()+2*margin))
+	draw.Draw(padded, padded.Bounds(), image.NewUniform(opaque(settings.BackgroundColor)), image.Point{}, draw.Src)
+	draw.Draw(padded, bounds.Sub(bounds.Min).Add(image.Pt(margin, margin)), img, bounds.Min, draw.Over)
+	return padded
+}
+
+// opaque returns the color as it appears on white paper, a missing color is transparent.
+func opaque(c color.Color) color.Color {
+	if c == nil {
+		return color.White
+	}
+	r, g, b, a := c.RGBA()
+	return color.RGBA64{R: uint16(r + 0xffff - a), G: uint16(g + 0xffff - a), B: uint16(b + 0xffff - a), A: 0xffff}
+}
+
+// luminance returns the relative luminance of the color as defined by WCAG, between 0 for black and 1 for white.
+func luminance(c color.Color) float64 {
+	r, g, b, _ := opaque(c).RGBA()
+	linear := func(channel uint32) float64 {
+		value := float64(channel) / 0xffff
+		if value <= 0.03928 {
+			return value / 12.92
+		}
+		return math.Pow((value+0.055)/1.055, 2.4)
+	}
+	return 0.2126*linear(r) + 0.7152*linear(g) + 0.0722*linear(b)
+}
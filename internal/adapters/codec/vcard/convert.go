@@ -0,0 +1,220 @@
+package vcardcodec
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/emersion/go-vcard"
+
+	"github.com/ulfschneider/qrvc/internal/application/config"
+	qrcard "github.com/ulfschneider/qrvc/internal/domain"
+)
+
+// fieldVersions are the fields that are not known in all vCard versions, together with the versions that know them.
+var fieldVersions = map[string][]string{
+	vcard.FieldKind:               {config.VCardVersion40},
+	vcard.FieldGender:             {config.VCardVersion40},
+	vcard.FieldAnniversary:        {config.VCardVersion40},
+	vcard.FieldLanguage:           {config.VCardVersion40},
+	vcard.FieldXML:                {config.VCardVersion40},
+	vcard.FieldClientPIDMap:       {config.VCardVersion40},
+	vcard.FieldMember:             {config.VCardVersion40},
+	vcard.FieldRelated:            {config.VCardVersion40},
+	vcard.FieldCalendarAddressURI: {config.VCardVersion40},
+	vcard.FieldCalendarURI:        {config.VCardVersion40},
+	vcard.FieldNickname:           {config.VCardVersion30, config.VCardVersion40},
+	vcard.FieldCategories:         {config.VCardVersion30, config.VCardVersion40},
+	vcard.FieldProductID:          {config.VCardVersion30, config.VCardVersion40},
+	vcard.FieldSource:             {config.VCardVersion30, config.VCardVersion40},
+	vcard.FieldIMPP:               {config.VCardVersion30, config.VCardVersion40},
+	"NAME":                        {config.VCardVersion30},
+	"CLASS":                       {config.VCardVersion30},
+	"SORT-STRING":                 {config.VCardVersion30},
+	"PROFILE":                     {config.VCardVersion30},
+	"LABEL":                       {config.VCardVersion21, config.VCardVersion30},
+	"MAILER":                      {config.VCardVersion21, config.VCardVersion30},
+	"AGENT":                       {config.VCardVersion21, config.VCardVersion30},
+}
+
+// fieldAliases are the extension fields that carry a vCard 4.0 field in older versions.
+var fieldAliases = map[string]string{
+	vcard.FieldAnniversary: "X-ANNIVERSARY",
+}
+
+// binaryFields are the fields that hold an image or another file, either embedded or linked.
+var binaryFields = []string{vcard.FieldPhoto, vcard.FieldLogo, vcard.FieldSound, vcard.FieldKey}
+
+// Convert returns a copy of the card in the given vCard version, together with the fields that can not be represented in that version.
+// Field names, parameters and encodings are translated, the card itself stays untouched.
+func (c *Codec) Convert(card vcard.Card, version string) (vcard.Card, []string) {
+	converted := qrcard.CopyCard(card)
+	translate(converted, version)
+	dropped := dropUnknownFields(converted, version)
+	converted.SetValue(vcard.FieldVersion, version)
+	return converted, dropped
+}
+
+// translate changes the fields of the card in place to the rules of the vCard version. Fields the version does not know are kept,
+// only a conversion drops them.
+func translate(card vcard.Card, version string) {
+	for field, alias := range fieldAliases {
+		if version == config.VCardVersion40 {
+			renameField(card, alias, field)
+		} else {
+			renameField(card, field, alias)
+		}
+	}
+
+	translateLabels(card, version)
+
+	for _, key := range binaryFields {
+		for _, field := range card[key] {
+			translateBinary(field, version)
+		}
+	}
+
+	for key, fields := range card {
+		for _, field := range fields {
+			translatePreference(field, version)
+			if version == config.VCardVersion40 && key == vcard.FieldEmail {
+				removeType(field, "internet")
+			}
+		}
+	}
+}
+
+// dropUnknownFields removes the fields the vCard version does not know from the card and returns their names.
+func dropUnknownFields(card vcard.Card, version string) []string {
+	dropped := []string{}
+	for key := range card {
+		if versions, ok := fieldVersions[key]; ok && !slices.Contains(versions, version) {
+			dropped = append(dropped, key)
+			delete(card, key)
+		}
+	}
+	slices.Sort(dropped)
+	return dropped
+}
+
+func renameField(card vcard.Card, from, to string) {
+	if fields, ok := card[from]; ok {
+		card[to] = append(card[to], fields...)
+		delete(card, from)
+	}
+}
+
+// translateLabels moves the delivery labels between the LABEL field of vCard 2.1 and 3.0 and the LABEL parameter of ADR in vCard 4.0.
+// A label is attached to the address with the same types, labels without such an address are dropped with the LABEL field.
+func translateLabels(card vcard.Card, version string) {
+	if version == config.VCardVersion40 {
+		remaining := []*vcard.Field{}
+		for _, label := range card["LABEL"] {
+			index := slices.IndexFunc(card[vcard.FieldAddress], func(address *vcard.Field) bool {
+				return address.Params.Get("LABEL") == "" && sameTypes(address, label)
+			})
+			if index < 0 {
+				remaining = append(remaining, label)
+				continue
+			}
+			card[vcard.FieldAddress][index].Params.Set("LABEL", label.Value)
+		}
+		if len(remaining) > 0 {
+			card["LABEL"] = remaining
+		} else {
+			delete(card, "LABEL")
+		}
+		return
+	}
+
+	for _, address := range card[vcard.FieldAddress] {
+		if label := address.Params.Get("LABEL"); label != "" {
+			delete(address.Params, "LABEL")
+			params := vcard.Params{}
+			if types := address.Params.Types(); len(types) > 0 {
+				params[vcard.ParamType] = slices.Clone(types)
+			}
+			card.Add("LABEL", &vcard.Field{Value: label, Params: params})
+		}
+	}
+}
+
+func sameTypes(a, b *vcard.Field) bool {
+	typesA := slices.Sorted(slices.Values(lower(a.Params.Types())))
+	typesB := slices.Sorted(slices.Values(lower(b.Params.Types())))
+	return slices.Equal(typesA, typesB)
+}
+
+func lower(values []string) []string {
+	lowered := make([]string, len(values))
+	for i, value := range values {
+		lowered[i] = strings.ToLower(value)
+	}
+	return lowered
+}
+
+// translateBinary moves embedded files between the ENCODING parameter of vCard 2.1 and 3.0 and the data URI of vCard 4.0,
+// and linked files between the VALUE parameter and the plain URI of vCard 4.0.
+func translateBinary(field *vcard.Field, version string) {
+	encoding := strings.ToLower(field.Params.Get("ENCODING"))
+	isEmbedded := encoding == "b" || encoding == "base64"
+
+	if version == config.VCardVersion40 {
+		if isEmbedded {
+			mediaType := "application/octet-stream"
+			if types := field.Params.Types(); len(types) > 0 {
+				mediaType = "image/" + strings.ToLower(types[0])
+			}
+			field.Value = "data:" + mediaType + ";base64," + field.Value
+			delete(field.Params, "ENCODING")
+			delete(field.Params, vcard.ParamType)
+		} else if value := strings.ToLower(field.Params.Get(vcard.ParamValue)); value == "uri" || value == "url" {
+			delete(field.Params, vcard.ParamValue)
+		}
+		return
+	}
+
+	embeddedEncoding := "b"
+	linkedValue := "uri"
+	if version == config.VCardVersion21 {
+		embeddedEncoding = "BASE64"
+		linkedValue = "URL"
+	}
+
+	if isEmbedded {
+		field.Params.Set("ENCODING", embeddedEncoding)
+	} else if mediaType, data, found := strings.Cut(strings.TrimPrefix(field.Value, "data:"), ";base64,"); found && strings.HasPrefix(field.Value, "data:") {
+		field.Params.Set("ENCODING", embeddedEncoding)
+		_, format, _ := strings.Cut(mediaType, "/")
+		if format != "" {
+			field.Params.Set(vcard.ParamType, strings.ToUpper(format))
+		}
+		delete(field.Params, vcard.ParamMediaType)
+		field.Value = data
+	} else if strings.Contains(field.Value, "://") {
+		field.Params.Set(vcard.ParamValue, linkedValue)
+	}
+}
+
+// translatePreference moves the preference between the TYPE=pref of vCard 2.1 and 3.0 and the PREF=1 of vCard 4.0.
+func translatePreference(field *vcard.Field, version string) {
+	if version == config.VCardVersion40 {
+		if field.Params.HasType("pref") {
+			removeType(field, "pref")
+			field.Params.Set(vcard.ParamPreferred, "1")
+		}
+	} else if field.Params.Get(vcard.ParamPreferred) != "" {
+		delete(field.Params, vcard.ParamPreferred)
+		if !field.Params.HasType("pref") {
+			field.Params.Add(vcard.ParamType, "pref")
+		}
+	}
+}
+
+func removeType(field *vcard.Field, fieldType string) {
+	types := slices.DeleteFunc(field.Params[vcard.ParamType], func(t string) bool { return strings.EqualFold(t, fieldType) })
+	if len(types) == 0 {
+		delete(field.Params, vcard.ParamType)
+	} else {
+		field.Params[vcard.ParamType] = types
+	}
+}
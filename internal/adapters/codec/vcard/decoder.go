@@ -0,0 +1,247 @@
+package vcardcodec
+
+import (
+	"fmt"
+	"io"
+	"mime/quotedprintable"
+	"slices"
+	"strings"
+
+	"github.com/emersion/go-vcard"
+	"golang.org/x/text/encoding/htmlindex"
+
+	"github.com/ulfschneider/qrvc/internal/application/config"
+)
+
+// encodings21 are the values of the ENCODING parameter of vCard 2.1, which may as well be given without the parameter name.
+var encodings21 = []string{"QUOTED-PRINTABLE", "BASE64", "8BIT", "7BIT"}
+
+// vcfLine is a logical line of vcf data, together with the physical lines it has been unfolded from.
+type vcfLine struct {
+	text string
+	raw  []string
+}
+
+// splitCards returns the lines of each card, from BEGIN:VCARD to END:VCARD. Lines outside of a card are left out.
+func splitCards(lines []vcfLine) [][]vcfLine {
+	cards := [][]vcfLine{}
+	for start := 0; start < len(lines); start++ {
+		if !strings.EqualFold(lines[start].text, "BEGIN:VCARD") {
+			continue
+		}
+		end := start + 1
+		for end < len(lines) && !strings.EqualFold(lines[end-1].text, "END:VCARD") {
+			end++
+		}
+		cards = append(cards, lines[start:end])
+		start = end - 1
+	}
+	return cards
+}
+
+// decodeCard decodes the lines of a single card, which can be of version 2.1, 3.0 or 4.0.
+// A vCard 2.1 is rewritten into the syntax of version 3.0 before, which is understood by the decoder:
+// quoted printable values are decoded, values in other charsets are converted into UTF-8,
+// and parameters without name, like TEL;CELL, get their TYPE or ENCODING name. The VERSION of the card is kept.
+func (c *Codec) decodeCard(lines []vcfLine) (vcard.Card, error) {
+	version := ""
+	for _, line := range lines {
+		if key, value, _ := strings.Cut(line.text, ":"); strings.EqualFold(key, "VERSION") {
+			version = strings.TrimSpace(value)
+		}
+	}
+
+	texts := make([]string, len(lines))
+	for i, line := range lines {
+		texts[i] = line.text
+		if version == config.VCardVersion21 {
+			text, err := normalizeLineV21(line.text)
+			if err != nil {
+				return nil, err
+			}
+			texts[i] = text
+		}
+	}
+
+	protected := make([]string, len(texts))
+	for i, text := range texts {
+		protected[i] = protectQuotedParams(text)
+	}
+
+	card, err := vcard.NewDecoder(strings.NewReader(strings.Join(protected, "\r\n") + "\r\n")).Decode()
+	if err != nil {
+		return nil, err
+	}
+	restoreParams(card, version)
+	unconform(card)
+	c.sources.remember(card, lines, texts, version)
+	return card, nil
+}
+
+// quotedParamProtector replaces the commas and backslashes of quoted parameter values by characters of the private use area,
+// because the decoder would split a quoted value at its commas and read its backslashes as escapes.
+var quotedParamProtector = strings.NewReplacer(",", "\uE000", "\\", "\uE001")
+
+var quotedParamRestorer = strings.NewReplacer("\uE000", ",", "\uE001", "\\")
+
+// paramParser reverts the escapes of RFC 6868 in parameter values.
+var paramParser = strings.NewReplacer("^^", "^", "^n", "\n", "^N", "\n", "^'", `"`)
+
+// protectQuotedParams protects the quoted parameter values of the line from the decoder.
+func protectQuotedParams(line string) string {
+	var protected strings.Builder
+	quoted := false
+	for i, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case !quoted && r == ':':
+			return protected.String() + line[i:]
+		case quoted:
+			protected.WriteString(quotedParamProtector.Replace(string(r)))
+			continue
+		}
+		protected.WriteRune(r)
+	}
+	return protected.String()
+}
+
+// restoreParams restores the protected characters of quoted parameter values and reverts their escapes of RFC 6868,
+// which vCard 2.1 does not know. A quoted TYPE is split at its commas, because types are written as TYPE="home,voice" as well.
+func restoreParams(card vcard.Card, version string) {
+	for _, fields := range card {
+		for _, field := range fields {
+			for name, values := range field.Params {
+				restored := []string{}
+				for _, value := range values {
+					value = quotedParamRestorer.Replace(value)
+					if version != config.VCardVersion21 {
+						value = paramParser.Replace(value)
+					}
+					if name == vcard.ParamType {
+						restored = append(restored, strings.Split(value, ",")...)
+					} else {
+						restored = append(restored, value)
+					}
+				}
+				field.Params[name] = restored
+			}
+		}
+	}
+}
+
+// unfold joins the physical lines that belong together. Lines that start with white space continue the line before,
+// as well as the lines after a quoted printable value that ends with a soft line break.
+func unfold(vcf []byte) []vcfLine {
+	lines := []vcfLine{}
+	for _, raw := range strings.Split(string(vcf), "\n") {
+		raw = strings.TrimRight(raw, "\r")
+
+		if n := len(lines); n > 0 {
+			last := &lines[n-1]
+			if isQuotedPrintable(last.text) && strings.HasSuffix(last.text, "=") {
+				last.text = strings.TrimSuffix(last.text, "=") + raw
+				last.raw = append(last.raw, raw)
+				continue
+			}
+			if strings.HasPrefix(raw, " ") || strings.HasPrefix(raw, "\t") {
+				last.text += raw[1:]
+				last.raw = append(last.raw, raw)
+				continue
+			}
+		}
+
+		if raw == "" {
+			//an empty line, like the one that ends a base64 value of vCard 2.1, is kept with the line before
+			if n := len(lines); n > 0 {
+				lines[n-1].raw = append(lines[n-1].raw, raw)
+			}
+			continue
+		}
+		lines = append(lines, vcfLine{text: raw, raw: []string{raw}})
+	}
+	return lines
+}
+
+func isQuotedPrintable(line string) bool {
+	head, _, found := strings.Cut(line, ":")
+	return found && strings.Contains(strings.ToUpper(head), "QUOTED-PRINTABLE")
+}
+
+// normalizeLineV21 rewrites a single line of a vCard 2.1 into the syntax of vCard 3.0.
+func normalizeLineV21(line string) (string, error) {
+	head, value, found := strings.Cut(line, ":")
+	if !found {
+		return line, nil
+	}
+
+	parts := strings.Split(head, ";")
+	if key := strings.ToUpper(parts[0]); key == "BEGIN" || key == "END" || key == "VERSION" {
+		return line, nil
+	}
+
+	params := []string{}
+	encoding, charset := "", ""
+	for _, param := range parts[1:] {
+		name, paramValue, hasName := strings.Cut(param, "=")
+		name = strings.ToUpper(strings.TrimSpace(name))
+		switch {
+		case !hasName && slices.Contains(encodings21, name):
+			encoding = name
+		case !hasName && name != "":
+			params = append(params, "TYPE="+strings.ToLower(name))
+		case name == "ENCODING":
+			encoding = strings.ToUpper(strings.TrimSpace(paramValue))
+		case name == "CHARSET":
+			charset = strings.TrimSpace(paramValue)
+		case name == "TYPE":
+			params = append(params, "TYPE="+strings.ToLower(strings.TrimSpace(paramValue)))
+		case name != "":
+			params = append(params, name+"="+paramValue)
+		}
+	}
+
+	switch encoding {
+	case "BASE64":
+		params = append(params, "ENCODING=b")
+		value = strings.Join(strings.Fields(value), "")
+	case "QUOTED-PRINTABLE":
+		decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(value)))
+		if err != nil {
+			return "", fmt.Errorf("Invalid quoted printable value in %s: %w", parts[0], err)
+		}
+		value = string(decoded)
+		fallthrough
+	default:
+		if charset != "" {
+			decoded, err := decodeCharset(value, charset)
+			if err != nil {
+				return "", err
+			}
+			value = decoded
+		}
+		value = valueFormatter.Replace(strings.ReplaceAll(value, "\r\n", "\n"))
+	}
+
+	if len(params) > 0 {
+		return parts[0] + ";" + strings.Join(params, ";") + ":" + value, nil
+	}
+	return parts[0] + ":" + value, nil
+}
+
+// decodeCharset converts the value from the charset into UTF-8.
+func decodeCharset(value, charset string) (string, error) {
+	if strings.EqualFold(charset, "UTF-8") || strings.EqualFold(charset, "US-ASCII") {
+		return value, nil
+	}
+
+	encoding, err := htmlindex.Get(charset)
+	if err != nil {
+		return "", fmt.Errorf("Unknown charset %s", charset)
+	}
+	decoded, err := encoding.NewDecoder().String(value)
+	if err != nil {
+		return "", fmt.Errorf("The value %s is not valid in charset %s", value, charset)
+	}
+	return decoded, nil
+}
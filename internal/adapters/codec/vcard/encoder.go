@@ -0,0 +1,277 @@
+package vcardcodec
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"maps"
+	"math"
+	"mime/quotedprintable"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/emersion/go-vcard"
+
+	"github.com/ulfschneider/qrvc/internal/application/config"
+	qrcard "github.com/ulfschneider/qrvc/internal/domain"
+)
+
+// globalPhoneNumber matches phone numbers that can be written as a tel: URI without a phone context and without changing the number, like +49-171-123-45.
+// Numbers with spaces stay text, because a URI can not carry the spaces and the number would come back changed.
+var globalPhoneNumber = regexp.MustCompile(`^\+[0-9][0-9().\-]*$`)
+
+const telScheme = "tel:"
+
+// skeletonTypes are the types of the empty phone numbers of the skeleton of a card.
+var skeletonTypes = []string{vcard.TypeCell, vcard.TypeWork, vcard.TypeHome}
+
+// conform returns a copy of the card that follows the rules of the vCard version, together with the field of the card each copied field stems from.
+// Fields without value are left out, unless they are kept, and then the skeleton of the card is completed.
+// A missing FN is joined from N in the name order. The card itself stays untouched, because it is shared with the editor and the other writers.
+func conform(card vcard.Card, version string, keepEmptyFields, familyFirst bool) (vcard.Card, map[*vcard.Field]*vcard.Field) {
+	conformed := qrcard.CopyCard(card)
+	origins := map[*vcard.Field]*vcard.Field{}
+	for key, fields := range card {
+		for i, field := range fields {
+			origins[conformed[key][i]] = field
+		}
+	}
+	translate(conformed, version)
+
+	if keepEmptyFields {
+		completeSkeleton(conformed, version)
+	} else {
+		removeEmptyFields(conformed)
+	}
+
+	//N is required before vCard 4.0
+	if version != config.VCardVersion40 && conformed.Name() == nil {
+		conformed.SetName(&vcard.Name{})
+	}
+
+	//FN is required since vCard 3.0
+	if version != config.VCardVersion21 {
+		qrcard.CompleteFormattedName(conformed, familyFirst)
+		if conformed.Get(vcard.FieldFormattedName) == nil {
+			conformed.SetValue(vcard.FieldFormattedName, "")
+		}
+	}
+
+	if version == config.VCardVersion40 {
+		for _, field := range conformed[vcard.FieldTelephone] {
+			if globalPhoneNumber.MatchString(field.Value) {
+				field.Params.Set(vcard.ParamValue, "uri")
+				field.Value = telScheme + field.Value
+			}
+		}
+	}
+
+	return conformed, origins
+}
+
+// structuredFields are the fields whose value is made of components separated by semicolons, they are empty when all components are empty.
+var structuredFields = []string{vcard.FieldName, vcard.FieldAddress, vcard.FieldOrganization, vcard.FieldGender}
+
+// removeEmptyFields removes the simple and structured fields without value, like an ADR of ;;;;;; or an empty GENDER.
+// A simple field with only a semicolon, like a NOTE of ;, has a value and is kept.
+func removeEmptyFields(card vcard.Card) {
+	for key, fields := range card {
+		structured := slices.Contains(structuredFields, key)
+		fields = slices.DeleteFunc(fields, func(field *vcard.Field) bool {
+			value := field.Value
+			if structured {
+				value = strings.ReplaceAll(value, ";", "")
+			}
+			return strings.TrimSpace(value) == ""
+		})
+		if len(fields) == 0 {
+			delete(card, key)
+		} else {
+			card[key] = fields
+		}
+	}
+}
+
+// completeSkeleton adds the empty fields of the skeleton that are missing in the card.
+func completeSkeleton(card vcard.Card, version string) {
+	if card.Name() == nil {
+		card.SetName(&vcard.Name{})
+	}
+	if card.Address() == nil {
+		card.SetAddress(&vcard.Address{})
+	}
+	if card.Get(vcard.FieldOrganization) == nil {
+		card.SetValue(vcard.FieldOrganization, ";")
+	}
+	for _, telType := range skeletonTypes {
+		if !slices.ContainsFunc(card[vcard.FieldTelephone], func(field *vcard.Field) bool { return field.Params.HasType(telType) }) {
+			card.Add(vcard.FieldTelephone, &vcard.Field{Params: vcard.Params{vcard.ParamType: {telType}}})
+		}
+	}
+	if version == config.VCardVersion40 && card.Get(vcard.FieldGender) == nil {
+		card.SetValue(vcard.FieldGender, "")
+	}
+}
+
+// unconform reverts the version specific values of a decoded card, to keep the card the same for all versions.
+func unconform(card vcard.Card) {
+	for _, field := range card[vcard.FieldTelephone] {
+		if strings.EqualFold(field.Params.Get(vcard.ParamValue), "uri") && strings.HasPrefix(strings.ToLower(field.Value), telScheme) {
+			delete(field.Params, vcard.ParamValue)
+			field.Value = field.Value[len(telScheme):]
+		}
+	}
+}
+
+// encodedLine is a line of an encoded card, together with the position of the line the field has been decoded from.
+type encodedLine struct {
+	key      string
+	text     string
+	position int
+}
+
+// encode writes the card in the format of its version. VERSION comes first. The fields that have been decoded keep their position,
+// and when they have not been changed, their line. The other fields follow, sorted by name, with sorted parameters.
+func encode(card vcard.Card, sources *fieldSources, keepEmptyFields, familyFirst bool) ([]byte, error) {
+	version := card.Value(vcard.FieldVersion)
+	if !slices.Contains(config.VCardVersions, version) {
+		return nil, fmt.Errorf("Unsupported vCard version %s, use one of %s", version, strings.Join(config.VCardVersions, ", "))
+	}
+
+	conformed, origins := conform(card, version, keepEmptyFields, familyFirst)
+
+	lines := []encodedLine{}
+	for key, fields := range conformed {
+		if strings.EqualFold(key, vcard.FieldVersion) {
+			continue
+		}
+		for _, field := range fields {
+			line := encodedLine{key: key, position: math.MaxInt}
+			source, found := sources.source(origins[field])
+			if found {
+				line.position = source.position
+			}
+			if found && source.unchanged(origins[field], version) {
+				line.text = source.line
+			} else {
+				text, err := formatLine(key, field, version)
+				if err != nil {
+					return nil, err
+				}
+				line.text = text
+			}
+			lines = append(lines, line)
+		}
+	}
+	slices.SortStableFunc(lines, func(a, b encodedLine) int {
+		return cmp.Or(cmp.Compare(a.position, b.position), cmp.Compare(a.key, b.key))
+	})
+
+	var buf bytes.Buffer
+	buf.WriteString("BEGIN:VCARD\r\n")
+	buf.WriteString("VERSION:" + version + "\r\n")
+	for _, line := range lines {
+		buf.WriteString(line.text + "\r\n")
+	}
+	buf.WriteString("END:VCARD\r\n")
+
+	return buf.Bytes(), nil
+}
+
+var valueFormatter = strings.NewReplacer("\\", "\\\\", "\n", "\\n", ",", "\\,")
+
+// listFormatter escapes the values of text properties that are lists, whose commas separate the values and are not escaped.
+var listFormatter = strings.NewReplacer("\\", "\\\\", "\n", "\\n")
+
+// listFields are the text properties that hold a list of values separated by commas.
+var listFields = []string{vcard.FieldCategories, vcard.FieldNickname}
+
+// paramFormatter escapes parameter values as in RFC 6868.
+var paramFormatter = strings.NewReplacer("^", "^^", "\r\n", "^n", "\n", "^n", `"`, "^'")
+
+func formatLine(key string, field *vcard.Field, version string) (string, error) {
+	value := field.Value
+	params := field.Params
+
+	if version == config.VCardVersion21 {
+		//vCard 2.1 does not escape values, other characters than ASCII and line breaks are quoted printable
+		if needsQuotedPrintable(value) {
+			params = maps.Clone(params)
+			params.Set("ENCODING", "QUOTED-PRINTABLE")
+			if !isASCII(value) {
+				params.Set("CHARSET", "UTF-8")
+			}
+			var err error
+			if value, err = encodeQuotedPrintable(value); err != nil {
+				return "", err
+			}
+		}
+	} else if slices.Contains(listFields, key) {
+		value = listFormatter.Replace(value)
+	} else if !isURI(key, field, version) {
+		value = valueFormatter.Replace(value)
+	}
+
+	var line strings.Builder
+	if field.Group != "" {
+		line.WriteString(field.Group + ".")
+	}
+	line.WriteString(key)
+	for _, param := range slices.Sorted(maps.Keys(params)) {
+		for _, paramValue := range params[param] {
+			line.WriteString(";" + param + "=" + formatParamValue(paramValue, version))
+		}
+	}
+	line.WriteString(":" + value)
+
+	return line.String(), nil
+}
+
+// formatParamValue escapes a parameter value of vCard 3.0 and 4.0 as in RFC 6868 and puts it into double quotes
+// when it holds a colon, a semicolon or a comma. vCard 2.1 neither quotes nor escapes parameter values.
+func formatParamValue(value string, version string) string {
+	if version == config.VCardVersion21 {
+		return value
+	}
+	value = paramFormatter.Replace(value)
+	if strings.ContainsAny(value, ":;,") {
+		return `"` + value + `"`
+	}
+	return value
+}
+
+// isURI tells whether the value is a URI of vCard 4.0, which is not escaped like text.
+func isURI(key string, field *vcard.Field, version string) bool {
+	return version == config.VCardVersion40 && (slices.Contains(binaryFields, key) || strings.EqualFold(field.Params.Get(vcard.ParamValue), "uri"))
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+func needsQuotedPrintable(s string) bool {
+	return !isASCII(s) || strings.ContainsAny(s, "\r\n")
+}
+
+// encodeQuotedPrintable encodes the value with line breaks as =0D=0A, because a plain line break would end the field.
+func encodeQuotedPrintable(value string) (string, error) {
+	value = strings.ReplaceAll(strings.ReplaceAll(value, "\r\n", "\n"), "\n", "\r\n")
+
+	var buf bytes.Buffer
+	writer := quotedprintable.NewWriter(&buf)
+	writer.Binary = true
+	if _, err := writer.Write([]byte(value)); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
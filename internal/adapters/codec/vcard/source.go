@@ -0,0 +1,113 @@
+package vcardcodec
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/emersion/go-vcard"
+
+	qrcard "github.com/ulfschneider/qrvc/internal/domain"
+)
+
+// fieldSource is the line a field has been decoded from, with its physical lines as they have been read. A field that has not been changed
+// since decoding is written back as these lines, to keep the group, the order of the parameters, the escaping and the folding as they were.
+type fieldSource struct {
+	line     string
+	position int
+	version  string
+	decoded  *vcard.Field
+}
+
+// fieldSources remembers the source of each field a codec has decoded, until its card is forgotten. The codec is shared by the workers of a batch.
+type fieldSources struct {
+	mutex   sync.Mutex
+	sources map[*vcard.Field]fieldSource
+}
+
+func newFieldSources() *fieldSources {
+	return &fieldSources{sources: map[*vcard.Field]fieldSource{}}
+}
+
+// remember assigns the lines of a card to the fields that have been decoded from them, a codec without sources remembers nothing. The texts are the lines
+// in the syntax the decoder has read, which is the syntax of vCard 3.0 for a vCard 2.1.
+// Fields of a name that does not appear as often in the lines as in the card are left out, because their lines can not be told apart.
+func (fs *fieldSources) remember(card vcard.Card, lines []vcfLine, texts []string, version string) {
+	if fs == nil {
+		return
+	}
+	keys := make([]string, len(texts))
+	counts := map[string]int{}
+	for i, text := range texts {
+		keys[i] = lineKey(text)
+		counts[keys[i]]++
+	}
+
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	assigned := map[string]int{}
+	for i, key := range keys {
+		if key == "" || counts[key] != len(card[key]) {
+			continue
+		}
+		field := card[key][assigned[key]]
+		assigned[key]++
+
+		fs.sources[field] = fieldSource{line: strings.Join(lines[i].raw, "\r\n"), position: i, version: version, decoded: qrcard.CopyField(field)}
+	}
+}
+
+// source returns the source of a decoded field, which is found as well when the field has been changed.
+// A codec that has not been created by a constructor has no sources.
+func (fs *fieldSources) source(field *vcard.Field) (fieldSource, bool) {
+	if fs == nil || field == nil {
+		return fieldSource{}, false
+	}
+
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	source, found := fs.sources[field]
+	return source, found
+}
+
+// forget removes the sources of the fields of the card.
+func (fs *fieldSources) forget(card vcard.Card) {
+	if fs == nil {
+		return
+	}
+
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	for _, fields := range card {
+		for _, field := range fields {
+			delete(fs.sources, field)
+		}
+	}
+}
+
+// unchanged tells whether the field is still the same as it has been decoded and is written in the version it has been read in.
+func (source fieldSource) unchanged(field *vcard.Field, version string) bool {
+	return source.version == version &&
+		field.Value == source.decoded.Value &&
+		field.Group == source.decoded.Group &&
+		maps.EqualFunc(field.Params, source.decoded.Params, slices.Equal)
+}
+
+// lineKey returns the upper case field name of the line without its group, or an empty string for the lines that are no field.
+func lineKey(line string) string {
+	head, _, found := strings.Cut(line, ":")
+	if !found {
+		return ""
+	}
+	name, _, _ := strings.Cut(head, ";")
+	if _, afterGroup, grouped := strings.Cut(name, "."); grouped {
+		name = afterGroup
+	}
+	key := strings.ToUpper(strings.TrimSpace(name))
+	if key == "BEGIN" || key == "END" {
+		return ""
+	}
+	return key
+}
@@ -1,33 +1,64 @@
 package vcardcodec
 
 import (
-	"bytes"
+	"errors"
 
 	"github.com/emersion/go-vcard"
+
+	"github.com/ulfschneider/qrvc/internal/application/config"
 )
 
+// Codec reads and writes vCards. The fields of cards the codec has decoded are written back by the same codec as they have been read,
+// as long as they have not been changed.
 type Codec struct {
+	keepEmptyFields bool
+	familyFirst     bool
+	sources         *fieldSources
 }
 
-func NewCodec() Codec {
-	return Codec{}
+// NewCodec creates a codec that leaves out the fields without value when it writes a card. When the settings keep the empty fields,
+// the codec writes them as well, and adds empty N, ADR, ORG and TEL fields, and GENDER for vCard 4.0, when the card has none of them.
+// This is the full skeleton some tools expect. A card without FN gets the FN joined from N in the name order of the settings.
+func NewCodec(settings config.Settings) Codec {
+	return Codec{
+		keepEmptyFields: settings.KeepEmptyFields,
+		familyFirst:     settings.NameOrder == config.NameOrderFamilyFirst,
+		sources:         newFieldSources(),
+	}
 }
 
+// Encode writes the card following the rules of the vCard version in its VERSION field.
 func (c *Codec) Encode(card vcard.Card) ([]byte, error) {
-	var buf bytes.Buffer
-	enc := vcard.NewEncoder(&buf)
-	if err := enc.Encode(card); err != nil {
-		return []byte{}, err
+	return encode(card, c.sources, c.keepEmptyFields, c.familyFirst)
+}
+
+// Decode reads the first card of the vcf data, which can be of version 2.1, 3.0 or 4.0.
+func (c *Codec) Decode(vcf []byte) (vcard.Card, error) {
+	cards := splitCards(unfold(vcf))
+	if len(cards) == 0 {
+		return nil, errors.New("No vCard found")
 	}
+	return c.decodeCard(cards[0])
+}
 
-	return buf.Bytes(), nil
+// Forget lets go of the lines the card has been decoded from, the card is written anew from then on.
+// A batch forgets each card when it is done with it, to not keep the lines of all cards in memory.
+func (c *Codec) Forget(card vcard.Card) {
+	c.sources.forget(card)
 }
 
-func (c *Codec) Decode(vcf []byte) (vcard.Card, error) {
-	dec := vcard.NewDecoder(bytes.NewBuffer(vcf))
-	card, err := dec.Decode()
-	if err != nil {
-		return nil, err
+// DecodeAll decodes all cards of the vcf data, in the order they appear. Data without any card is an error.
+func (c *Codec) DecodeAll(vcf []byte) ([]vcard.Card, error) {
+	cards := []vcard.Card{}
+	for _, lines := range splitCards(unfold(vcf)) {
+		card, err := c.decodeCard(lines)
+		if err != nil {
+			return nil, err
+		}
+		cards = append(cards, card)
+	}
+	if len(cards) == 0 {
+		return nil, errors.New("No vCard found")
 	}
-	return card, nil
+	return cards, nil
 }
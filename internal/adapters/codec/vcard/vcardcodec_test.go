@@ -1,16 +1,468 @@
 package vcardcodec_test
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/emersion/go-vcard"
 	"github.com/stretchr/testify/assert"
 	vcardcodec "github.com/ulfschneider/qrvc/internal/adapters/codec/vcard"
+	"github.com/ulfschneider/qrvc/internal/application/config"
 	testutil "github.com/ulfschneider/qrvc/internal/test/util"
 )
 
 func TestVCardCodec(t *testing.T) {
 	card := testutil.CreateCard()
-	codec := vcardcodec.NewCodec()
+	codec := vcardcodec.NewCodec(config.Settings{})
 	vcf, _ := codec.Encode(card)
 	assert.Equal(t, testutil.ExpectedVCF, testutil.NormalizeNewLines(string(vcf)))
 }
+
+func TestVCardCodecDecodeAll(t *testing.T) {
+	card := testutil.CreateConformingCard()
+	codec := vcardcodec.NewCodec(config.Settings{})
+	vcf, _ := codec.Encode(card)
+
+	cards, err := codec.DecodeAll(append(append([]byte{}, vcf...), vcf...))
+	assert.NoError(t, err)
+	assert.Len(t, cards, 2)
+	assert.Equal(t, card, cards[0])
+	assert.Equal(t, card, cards[1])
+
+	_, err = codec.DecodeAll([]byte{})
+	assert.Error(t, err)
+
+	_, err = codec.DecodeAll([]byte("no vcard"))
+	assert.Error(t, err)
+}
+
+func createVersionCard(version string) vcard.Card {
+	card := vcard.Card{}
+	card.SetValue(vcard.FieldVersion, version)
+	card.SetName(&vcard.Name{GivenName: "Jürgen", FamilyName: "Müller"})
+	card.SetGender(vcard.SexMale, "")
+	card.SetValue(vcard.FieldKind, "individual")
+	card.SetValue(vcard.FieldNote, "First line, second part\nSecond line")
+	card.Add(vcard.FieldTelephone, &vcard.Field{Value: "+49 171 123-45", Params: vcard.Params{vcard.ParamType: {vcard.TypeCell, "pref"}}})
+	card.Add(vcard.FieldTelephone, &vcard.Field{Value: "0171 12345", Params: vcard.Params{vcard.ParamType: {vcard.TypeHome}}})
+	return card
+}
+
+func TestVCardCodecVersions(t *testing.T) {
+	codec := vcardcodec.NewCodec(config.Settings{})
+
+	vcf, err := codec.Encode(createVersionCard("4.0"))
+	assert.NoError(t, err)
+	assert.Equal(t, testutil.NormalizeNewLines(`BEGIN:VCARD
+VERSION:4.0
+FN:Jürgen Müller
+GENDER:M
+KIND:individual
+N:Müller;Jürgen;;;
+NOTE:First line\, second part\nSecond line
+TEL;PREF=1;TYPE=cell:+49 171 123-45
+TEL;TYPE=home:0171 12345
+END:VCARD
+`), testutil.NormalizeNewLines(string(vcf)))
+
+	//a phone number in E.164 format or without spaces is a tel: URI
+	card := createVersionCard("4.0")
+	card.Get(vcard.FieldTelephone).Value = "+491711234567"
+	vcf, err = codec.Encode(card)
+	assert.NoError(t, err)
+	assert.Contains(t, string(vcf), "TEL;PREF=1;TYPE=cell;VALUE=uri:tel:+491711234567")
+
+	card.Get(vcard.FieldTelephone).Value = "+49-171-123-45"
+	vcf, err = codec.Encode(card)
+	assert.NoError(t, err)
+	assert.Contains(t, string(vcf), "TEL;PREF=1;TYPE=cell;VALUE=uri:tel:+49-171-123-45")
+
+	//the decoded number is the number of the card
+	card, err = codec.Decode(vcf)
+	assert.NoError(t, err)
+	assert.Equal(t, "+49-171-123-45", card.Get(vcard.FieldTelephone).Value)
+	assert.Equal(t, "", card.Get(vcard.FieldTelephone).Params.Get(vcard.ParamValue))
+
+	//a number with spaces stays text and comes back unchanged
+	vcf, err = codec.Encode(createVersionCard("4.0"))
+	assert.NoError(t, err)
+	card, err = codec.Decode(vcf)
+	assert.NoError(t, err)
+	assert.Equal(t, "+49 171 123-45", card.Get(vcard.FieldTelephone).Value)
+
+	//fields the version does not know are kept, only a conversion leaves them out
+	vcf, err = codec.Encode(createVersionCard("3.0"))
+	assert.NoError(t, err)
+	assert.Equal(t, testutil.NormalizeNewLines(`BEGIN:VCARD
+VERSION:3.0
+FN:Jürgen Müller
+GENDER:M
+KIND:individual
+N:Müller;Jürgen;;;
+NOTE:First line\, second part\nSecond line
+TEL;TYPE=cell;TYPE=pref:+49 171 123-45
+TEL;TYPE=home:0171 12345
+END:VCARD
+`), testutil.NormalizeNewLines(string(vcf)))
+
+	vcf, err = codec.Encode(createVersionCard("2.1"))
+	assert.NoError(t, err)
+	assert.Equal(t, testutil.NormalizeNewLines(`BEGIN:VCARD
+VERSION:2.1
+GENDER:M
+KIND:individual
+N;CHARSET=UTF-8;ENCODING=QUOTED-PRINTABLE:M=C3=BCller;J=C3=BCrgen;;;
+NOTE;ENCODING=QUOTED-PRINTABLE:First line, second part=0D=0ASecond line
+TEL;TYPE=cell;TYPE=pref:+49 171 123-45
+TEL;TYPE=home:0171 12345
+END:VCARD
+`), testutil.NormalizeNewLines(string(vcf)))
+
+	//the PREF of vCard 4.0 is a type before
+	card = createVersionCard("3.0")
+	card.Get(vcard.FieldTelephone).Params = vcard.Params{vcard.ParamPreferred: {"1"}}
+	vcf, err = codec.Encode(card)
+	assert.NoError(t, err)
+	assert.Contains(t, string(vcf), "TEL;TYPE=pref:+49 171 123-45")
+
+	//the card itself is not changed
+	card = createVersionCard("2.1")
+	_, err = codec.Encode(card)
+	assert.NoError(t, err)
+	assert.Equal(t, createVersionCard("2.1"), card)
+
+	_, err = codec.Encode(createVersionCard("5.0"))
+	assert.Error(t, err)
+}
+
+func TestVCardCodecDecodeV21(t *testing.T) {
+	codec := vcardcodec.NewCodec(config.Settings{})
+
+	card, err := codec.Decode([]byte("BEGIN:VCARD\r\n" +
+		"VERSION:2.1\r\n" +
+		"N;CHARSET=ISO-8859-1;ENCODING=QUOTED-PRINTABLE:M=FCller;J=FCrgen\r\n" +
+		"NOTE;ENCODING=QUOTED-PRINTABLE:First line, =\r\n" +
+		"second part=0D=0ASecond line\r\n" +
+		"TEL;CELL;VOICE:+49 171 123-45\r\n" +
+		"PHOTO;JPEG;BASE64:\r\n" +
+		"  /9j/4AAQ\r\n" +
+		"  SkZJRg==\r\n" +
+		"\r\n" +
+		"END:VCARD\r\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Müller", card.Name().FamilyName)
+	assert.Equal(t, "Jürgen", card.Name().GivenName)
+	assert.Equal(t, "First line, second part\nSecond line", card.Value(vcard.FieldNote))
+	assert.Equal(t, "+49 171 123-45", card.Value(vcard.FieldTelephone))
+	assert.Equal(t, []string{"cell", "voice"}, card.Get(vcard.FieldTelephone).Params.Types())
+	assert.Equal(t, "/9j/4AAQSkZJRg==", card.Value(vcard.FieldPhoto))
+	assert.Equal(t, "b", card.Get(vcard.FieldPhoto).Params.Get("ENCODING"))
+	assert.Equal(t, "2.1", card.Value(vcard.FieldVersion))
+
+	//our own vCard 2.1 is read back unchanged
+	vcf, err := codec.Encode(createVersionCard("2.1"))
+	assert.NoError(t, err)
+	card, err = codec.Decode(vcf)
+	assert.NoError(t, err)
+	assert.Equal(t, "First line, second part\nSecond line", card.Value(vcard.FieldNote))
+	assert.Equal(t, "Müller", card.Name().FamilyName)
+	assert.Equal(t, "Jürgen", card.Name().GivenName)
+
+	_, err = codec.Decode([]byte("BEGIN:VCARD\r\nVERSION:2.1\r\nN;CHARSET=NO-SUCH-CHARSET:Müller\r\nEND:VCARD\r\n"))
+	assert.Error(t, err)
+}
+
+func TestVCardCodecConvert(t *testing.T) {
+	codec := vcardcodec.NewCodec(config.Settings{})
+
+	card := createVersionCard("4.0")
+	card.SetValue(vcard.FieldAnniversary, "20100612")
+	card.Add(vcard.FieldAddress, &vcard.Field{Value: ";;Main Street 1;Berlin;;10115;Germany", Params: vcard.Params{vcard.ParamType: {vcard.TypeWork}, "LABEL": {"Main Street 1\n10115 Berlin"}}})
+	card.SetValue(vcard.FieldPhoto, "data:image/jpeg;base64,/9j/4AAQ")
+
+	converted, dropped := codec.Convert(card, "3.0")
+	assert.Equal(t, []string{vcard.FieldGender, vcard.FieldKind}, dropped)
+	assert.Equal(t, "3.0", converted.Value(vcard.FieldVersion))
+	assert.Equal(t, "20100612", converted.Value("X-ANNIVERSARY"))
+	assert.Equal(t, "Main Street 1\n10115 Berlin", converted.Value("LABEL"))
+	assert.Equal(t, []string{vcard.TypeWork}, converted.Get("LABEL").Params.Types())
+	assert.Equal(t, "", converted.Get(vcard.FieldAddress).Params.Get("LABEL"))
+	assert.Equal(t, "/9j/4AAQ", converted.Value(vcard.FieldPhoto))
+	assert.Equal(t, "b", converted.Get(vcard.FieldPhoto).Params.Get("ENCODING"))
+	assert.Equal(t, []string{"JPEG"}, converted.Get(vcard.FieldPhoto).Params[vcard.ParamType])
+	assert.True(t, converted.Get(vcard.FieldTelephone).Params.HasType("pref"))
+
+	//the card itself is not changed
+	assert.Equal(t, "4.0", card.Value(vcard.FieldVersion))
+	assert.Equal(t, "individual", card.Value(vcard.FieldKind))
+
+	//and back again
+	back, dropped := codec.Convert(converted, "4.0")
+	assert.Empty(t, dropped)
+	assert.Equal(t, "20100612", back.Value(vcard.FieldAnniversary))
+	assert.Equal(t, "Main Street 1\n10115 Berlin", back.Get(vcard.FieldAddress).Params.Get("LABEL"))
+	assert.Equal(t, "data:image/jpeg;base64,/9j/4AAQ", back.Value(vcard.FieldPhoto))
+	assert.Equal(t, "1", back.Get(vcard.FieldTelephone).Params.Get(vcard.ParamPreferred))
+
+	converted, dropped = codec.Convert(card, "2.1")
+	assert.Equal(t, []string{vcard.FieldGender, vcard.FieldKind}, dropped)
+	assert.Equal(t, "BASE64", converted.Get(vcard.FieldPhoto).Params.Get("ENCODING"))
+	vcf, err := codec.Encode(converted)
+	assert.NoError(t, err)
+	assert.Contains(t, string(vcf), "VERSION:2.1")
+}
+
+func TestVCardCodecNameOrder(t *testing.T) {
+	card := vcard.Card{}
+	card.SetValue(vcard.FieldVersion, "3.0")
+	card.SetName(&vcard.Name{GivenName: "János", FamilyName: "Nagy", HonorificPrefix: "Dr."})
+
+	//a missing FN is joined from N in the name order
+	codec := vcardcodec.NewCodec(config.Settings{NameOrder: config.NameOrderFamilyFirst})
+	vcf, err := codec.Encode(card)
+	assert.NoError(t, err)
+	assert.Contains(t, string(vcf), "FN:Dr. Nagy János\r\n")
+
+	codec = vcardcodec.NewCodec(config.Settings{})
+	vcf, err = codec.Encode(card)
+	assert.NoError(t, err)
+	assert.Contains(t, string(vcf), "FN:Dr. János Nagy\r\n")
+	assert.Nil(t, card.Get(vcard.FieldFormattedName))
+}
+
+func TestVCardCodecEmptyFields(t *testing.T) {
+	card := vcard.Card{}
+	card.SetValue(vcard.FieldVersion, "4.0")
+	card.SetValue(vcard.FieldFormattedName, "Johnny")
+	card.SetAddress(&vcard.Address{})
+	card.SetValue(vcard.FieldOrganization, ";")
+	card.SetGender("", "")
+	card.SetValue(vcard.FieldTitle, " ")
+	card.SetValue(vcard.FieldNote, ";")
+	card.Add(vcard.FieldTelephone, &vcard.Field{Params: vcard.Params{vcard.ParamType: {vcard.TypeCell}}})
+	card.Add(vcard.FieldTelephone, &vcard.Field{Value: "+49 171 1234567", Params: vcard.Params{vcard.ParamType: {vcard.TypeWork}}})
+
+	//fields without value are left out, a simple field with only a semicolon has a value
+	codec := vcardcodec.NewCodec(config.Settings{})
+	vcf, err := codec.Encode(card)
+	assert.NoError(t, err)
+	assert.Equal(t, testutil.NormalizeNewLines(`BEGIN:VCARD
+VERSION:4.0
+FN:Johnny
+NOTE:;
+TEL;TYPE=work:+49 171 1234567
+END:VCARD
+`), testutil.NormalizeNewLines(string(vcf)))
+
+	//but N is kept, because vCard 3.0 requires it
+	card.SetValue(vcard.FieldVersion, "3.0")
+	card.SetName(&vcard.Name{})
+	vcf, err = codec.Encode(card)
+	assert.NoError(t, err)
+	assert.Equal(t, testutil.NormalizeNewLines(`BEGIN:VCARD
+VERSION:3.0
+FN:Johnny
+N:;;;;
+NOTE:;
+TEL;TYPE=work:+49 171 1234567
+END:VCARD
+`), testutil.NormalizeNewLines(string(vcf)))
+
+	//a codec that keeps them completes the skeleton
+	card = vcard.Card{}
+	card.SetValue(vcard.FieldVersion, "4.0")
+	card.SetValue(vcard.FieldFormattedName, "Johnny")
+	card.SetValue(vcard.FieldTitle, "")
+	codec = vcardcodec.NewCodec(config.Settings{KeepEmptyFields: true})
+	vcf, err = codec.Encode(card)
+	assert.NoError(t, err)
+	assert.Equal(t, testutil.NormalizeNewLines(`BEGIN:VCARD
+VERSION:4.0
+ADR:;;;;;;
+FN:Johnny
+GENDER:
+N:;;;;
+ORG:;
+TEL;TYPE=cell:
+TEL;TYPE=work:
+TEL;TYPE=home:
+TITLE:
+END:VCARD
+`), testutil.NormalizeNewLines(string(vcf)))
+}
+
+func TestVCardCodecGolden(t *testing.T) {
+	codec := vcardcodec.NewCodec(config.Settings{})
+
+	for name, vcf := range testutil.GoldenVCards() {
+		t.Run(name, func(t *testing.T) {
+			card, err := codec.Decode(vcf)
+			assert.NoError(t, err)
+
+			encoded, err := codec.Encode(card)
+			assert.NoError(t, err)
+			assert.Equal(t, string(vcf), string(encoded))
+		})
+	}
+
+	//a changed field is written anew at its place, the other fields keep their lines
+	card, err := codec.Decode(testutil.GoldenVCards()["ios.vcf"])
+	assert.NoError(t, err)
+	card.Get(vcard.FieldTitle).Value = "Senior Developer"
+	card.SetValue(vcard.FieldRole, "Team lead")
+	encoded, err := codec.Encode(card)
+	assert.NoError(t, err)
+	lines := strings.Split(string(encoded), "\r\n")
+	assert.Equal(t, "TITLE:Senior Developer", lines[7])
+	assert.Equal(t, "item1.EMAIL;type=INTERNET;type=pref:johnny@example.com", lines[8])
+	assert.Equal(t, "ROLE:Team lead", lines[len(lines)-3])
+
+	//another codec does not know the lines of the card and writes all fields anew
+	otherCodec := vcardcodec.NewCodec(config.Settings{})
+	encoded, err = otherCodec.Encode(card)
+	assert.NoError(t, err)
+	assert.Contains(t, string(encoded), "EMAIL;TYPE=INTERNET;TYPE=WORK:j.appleseed@example.com\r\n")
+}
+
+func TestVCardCodecFolding(t *testing.T) {
+	vcf := testutil.NormalizeNewLines(`BEGIN:VCARD
+VERSION:3.0
+N:Appleseed;Johnny;;;
+FN:Johnny Appleseed
+NOTE:A note that is folded
+  after a space and
+	after a tab
+END:VCARD
+`)
+	vcf = strings.ReplaceAll(vcf, "\n", "\r\n")
+	codec := vcardcodec.NewCodec(config.Settings{})
+
+	//a folded line is unfolded to read its value
+	card, err := codec.Decode([]byte(vcf))
+	assert.NoError(t, err)
+	assert.Equal(t, "A note that is folded after a space andafter a tab", card.Value(vcard.FieldNote))
+
+	//an unchanged field keeps its folding
+	encoded, err := codec.Encode(card)
+	assert.NoError(t, err)
+	assert.Equal(t, vcf, string(encoded))
+
+	//a changed field is written anew on a single line
+	card.SetValue(vcard.FieldNote, "A new note")
+	encoded, err = codec.Encode(card)
+	assert.NoError(t, err)
+	assert.Contains(t, string(encoded), "\r\nNOTE:A new note\r\nEND:VCARD\r\n")
+
+	//the soft line breaks of quoted printable values of vCard 2.1 are kept as well
+	vcf = strings.ReplaceAll(testutil.NormalizeNewLines(`BEGIN:VCARD
+VERSION:2.1
+N:Schmidt;Anna
+NOTE;ENCODING=QUOTED-PRINTABLE:Erste Zeile=0D=0A=
+Zweite Zeile
+END:VCARD
+`), "\n", "\r\n")
+	card, err = codec.Decode([]byte(vcf))
+	assert.NoError(t, err)
+	assert.Equal(t, "Erste Zeile\nZweite Zeile", card.Value(vcard.FieldNote))
+	encoded, err = codec.Encode(card)
+	assert.NoError(t, err)
+	assert.Equal(t, vcf, string(encoded))
+}
+
+func TestVCardCodecParams(t *testing.T) {
+	codec := vcardcodec.NewCodec(config.Settings{})
+	card, err := codec.Decode([]byte(testutil.NormalizeNewLines(`BEGIN:VCARD
+VERSION:3.0
+N:Appleseed;Johnny;;;
+FN:Johnny Appleseed
+NOTE;LANGUAGE=en;X-SOURCE="Letter, 12 May ^'A^'^nPage 2":A note
+TEL;TYPE="work,voice":+1 555 1234
+CATEGORIES:Friends,Family
+END:VCARD
+`)))
+	assert.NoError(t, err)
+
+	//a quoted parameter value keeps its commas and is unescaped as in RFC 6868
+	assert.Equal(t, []string{"Letter, 12 May \"A\"\nPage 2"}, card.Get(vcard.FieldNote).Params["X-SOURCE"])
+	assert.Equal(t, []string{"work", "voice"}, card.Get(vcard.FieldTelephone).Params[vcard.ParamType])
+
+	//a changed field is written with its parameter values quoted and escaped, and the commas of a list are kept
+	card.Get(vcard.FieldNote).Params.Set("X-SOURCE", "Letter, 14 May \"B\"\nPage 3")
+	card.SetValue(vcard.FieldCategories, "Friends,Work")
+	encoded, err := codec.Encode(card)
+	assert.NoError(t, err)
+	assert.Contains(t, string(encoded), "\r\nNOTE;LANGUAGE=en;X-SOURCE=\"Letter, 14 May ^'B^'^nPage 3\":A note\r\n")
+	assert.Contains(t, string(encoded), "\r\nCATEGORIES:Friends,Work\r\n")
+
+	//and read again as written
+	decoded, err := codec.Decode(encoded)
+	assert.NoError(t, err)
+	assert.Equal(t, card.Get(vcard.FieldNote).Params, decoded.Get(vcard.FieldNote).Params)
+	assert.Equal(t, "Friends,Work", decoded.Value(vcard.FieldCategories))
+}
+
+func TestVCardCodecUnknownFields(t *testing.T) {
+	expected := map[string]string{
+		config.VCardVersion21: `BEGIN:VCARD
+VERSION:2.1
+CATEGORIES:Friends,Family
+GENDER:M
+IMPP;TYPE=home:xmpp:johnny@example.com
+N:Appleseed;Johnny;;;
+NICKNAME:John
+END:VCARD
+`,
+		config.VCardVersion30: `BEGIN:VCARD
+VERSION:3.0
+CATEGORIES:Friends,Family
+FN:Johnny Appleseed
+GENDER:M
+IMPP;TYPE=home:xmpp:johnny@example.com
+N:Appleseed;Johnny;;;
+NICKNAME:John
+END:VCARD
+`,
+	}
+
+	//fields the version does not know are written, only a conversion leaves them out
+	for version, vcf := range expected {
+		t.Run(version, func(t *testing.T) {
+			codec := vcardcodec.NewCodec(config.Settings{})
+			card := vcard.Card{}
+			card.SetValue(vcard.FieldVersion, version)
+			card.SetName(&vcard.Name{GivenName: "Johnny", FamilyName: "Appleseed"})
+			card.SetValue(vcard.FieldNickname, "John")
+			card.Add(vcard.FieldIMPP, &vcard.Field{Value: "xmpp:johnny@example.com", Params: vcard.Params{vcard.ParamType: {vcard.TypeHome}}})
+			card.SetValue(vcard.FieldCategories, "Friends,Family")
+			card.SetGender(vcard.SexMale, "")
+
+			encoded, err := codec.Encode(card)
+			assert.NoError(t, err)
+			assert.Equal(t, vcf, testutil.NormalizeNewLines(string(encoded)))
+
+			decoded, err := codec.Decode(encoded)
+			assert.NoError(t, err)
+			encoded, err = codec.Encode(decoded)
+			assert.NoError(t, err)
+			assert.Equal(t, vcf, testutil.NormalizeNewLines(string(encoded)))
+
+			converted, dropped := codec.Convert(decoded, version)
+			assert.Nil(t, converted.Get(vcard.FieldGender))
+			assert.Contains(t, dropped, vcard.FieldGender)
+		})
+	}
+}
+
+func TestVCardCodecForget(t *testing.T) {
+	vcf := testutil.GoldenVCards()["ios.vcf"]
+	codec := vcardcodec.NewCodec(config.Settings{})
+	card, err := codec.Decode(vcf)
+	assert.NoError(t, err)
+
+	//a forgotten card is written anew
+	codec.Forget(card)
+	encoded, err := codec.Encode(card)
+	assert.NoError(t, err)
+	assert.NotEqual(t, string(vcf), string(encoded))
+	assert.Contains(t, string(encoded), "EMAIL;TYPE=INTERNET;TYPE=WORK:j.appleseed@example.com\r\n")
+}
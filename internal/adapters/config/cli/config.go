@@ -2,9 +2,14 @@ package configcli
 
 import (
 	"errors"
+	"fmt"
 	"image/color"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/mazznoer/csscolorparser"
@@ -14,12 +19,14 @@ import (
 	notifiercli "github.com/ulfschneider/qrvc/internal/adapters/notifier/cli"
 	"github.com/ulfschneider/qrvc/internal/application/config"
 	"github.com/ulfschneider/qrvc/internal/application/services"
+	qrcard "github.com/ulfschneider/qrvc/internal/domain"
 )
 
 type SettingsProvider struct {
 	flagSet        *pflag.FlagSet
 	versionService services.VersionService
 	userNotifier   notifiercli.UserNotifier
+	userConfigDir  string
 }
 
 type CLIFileSettings struct {
@@ -32,28 +39,115 @@ type FileSettings struct {
 	ReadVCardPath   string
 	WriteVCardPath  string
 	WriteQRCodePath string
+	QRCodeFormat    string
+	PDFPage         PageSize
+	PDFCaption      bool
+	LogoPath        string
+	Batch           bool
+	WriteDirPath    string
+	NameTemplate    string
+	CSVMappings     []string
+	CSVMappingPath  string
+}
+
+// The file formats a QR code can be written in.
+const (
+	FormatPNG = "png"
+	FormatSVG = "svg"
+	FormatPDF = "pdf"
+)
+
+var qrCodeFormats = []string{FormatPNG, FormatSVG, FormatPDF}
+
+// imageExtensions are the file extensions of the QR code images that can be read as input.
+var imageExtensions = []string{".png", ".jpg", ".jpeg"}
+
+// fieldName matches the name of a vCard field, like TEL or X-ANNIVERSARY.
+var fieldName = regexp.MustCompile(`^[A-Z][A-Z0-9-]*$`)
+
+// FormatVCard writes the vCard instead of the QR code, which is only possible when writing to stdout.
+const FormatVCard = "vcf"
+
+// StandardStream as input or output path reads from stdin or writes to stdout.
+const StandardStream = "-"
+
+const recoveryAuto = "auto"
+
+// PageSize is the width and height of a PDF page in millimeters.
+type PageSize struct {
+	Width  float64
+	Height float64
+}
+
+var pageSizes = map[string]PageSize{
+	"a4":     {Width: 210, Height: 297},
+	"a5":     {Width: 148, Height: 210},
+	"a6":     {Width: 105, Height: 148},
+	"letter": {Width: 215.9, Height: 279.4},
+	"card":   {Width: 85, Height: 55},
+}
+
+// WritesToStdout tells whether the QR code or the vCard is written to stdout.
+func (fs FileSettings) WritesToStdout() bool {
+	return fs.WriteQRCodePath == StandardStream || fs.WriteVCardPath == StandardStream
 }
 
 type CLISettings struct {
-	Bom        bool
-	AppVersion bool
+	Bom          bool
+	AppVersion   bool
+	PrintConfig  bool
+	ConfigValues []ConfigValue
+	ListProfiles bool
+	Profiles     []Profile
 }
 
 func NewSettingsProvider(versionService services.VersionService) SettingsProvider {
 	flagSet := pflag.NewFlagSet(os.Args[0], pflag.ExitOnError)
 	cliNotifier := notifiercli.NewUserNotifier()
-	return SettingsProvider{flagSet: flagSet, versionService: versionService, userNotifier: cliNotifier}
+	userConfigDir, err := os.UserConfigDir()
+	if err != nil {
+		//without a home folder there is no user file
+		userConfigDir = ""
+	}
+	return SettingsProvider{flagSet: flagSet, versionService: versionService, userNotifier: cliNotifier, userConfigDir: userConfigDir}
+}
+
+// SetUserConfigDir replaces the user config folder, which holds the user file qrvc/config.yaml or qrvc/config.toml. An empty folder leaves out the user file.
+func (sp *SettingsProvider) SetUserConfigDir(dir string) {
+	sp.userConfigDir = dir
 }
 
 func (sp *SettingsProvider) Load() (CLIFileSettings, error) {
 
 	silent := sp.flagSet.BoolP("silent", "s", false, "The silent mode will not interactively ask for input and instead requires a vCard input file.")
 
-	readVCardPath := sp.flagSet.StringP("input", "i", "", "The path and name of the vCard input file. When you provide a file name without extension, .vcf will automatically added as an extension.")
+	readVCardPath := sp.flagSet.StringP("input", "i", "", "The path and name of the vCard input file. When you provide a file name without extension, .vcf will automatically added as an extension.\nThe input can as well be a PNG or JPEG image of a QR code that carries a vCard or a MeCard. Use - to read the vCard from stdin, together with --silent or --convert, because the editor needs stdin for its input.")
+
+	writePath := sp.flagSet.StringP("output", "o", "", "The path and name for the output. Please do not add any file extension, as those will be added automatically.\nWill receive the extension of the QR code format (e.g. .png) for the QR code and .vcf for the vCard. The input file basename will be used by default,\nthe QR code of an image input gets .qr added to the name (e.g. badge.qr.png) to not overwrite the image. Use - to write the QR code to stdout instead, or the vCard with the format vcf. Messages are then written to stderr.")
+
+	batch := sp.flagSet.BoolP("batch", "n", false, "The batch mode converts many vCards without asking for input. The input can be a folder, a glob pattern (like \"team/*.vcf\") or a vCard file that holds many cards.\nThe output is the folder for the resulting files, one vCard and one QR code per contact.")
+
+	nameTemplate := sp.flagSet.String("name", "{family}-{given}", "The template for the output file names in batch mode. Placeholders are {family}, {given}, {additional}, {prefix}, {suffix}, {fn}, {org} and {title}.")
+
+	csvMappings := sp.flagSet.StringArray("csv-map", []string{}, "Maps a CSV column to a vCard field in the form Column=FIELD, like \"Mobile=TEL;TYPE=cell\", \"Company=ORG\" or \"Last name=N.family\".\nRepeat the flag for each column. Columns that are named like a vCard field are mapped automatically.\nAn input file with the extension .csv is converted in batch mode, one contact per row.")
+
+	csvMappingPath := sp.flagSet.String("csv-map-file", "", "The path and name of a file with one CSV column mapping per line, in the form of the csv-map flag.")
 
-	writePath := sp.flagSet.StringP("output", "o", "", "The path and name for the output. Please do not add any file extension, as those will be added automatically.\nWill receive the extension .png for the QR code and .vcf for the vCard. The input file basename will be used by default.")
+	jobs := sp.flagSet.IntP("jobs", "j", runtime.NumCPU(), "The number of contacts that are converted in parallel in batch mode.")
 
-	vCardVersion := sp.flagSet.StringP("cardversion", "c", "3.0", "The vCard version to create.")
+	qrCodeFormat := sp.flagSet.StringP("format", "t", FormatPNG, "The file format of the QR code, one of "+strings.Join(qrCodeFormats, ", ")+". The format is used as the file extension of the QR code.\nWhen writing to stdout, the format "+FormatVCard+" writes the vCard instead of the QR code.")
+
+	pdfPage := sp.flagSet.StringP("page", "p", "a4", "The page size of a PDF QR code. This can be one of a4, a5, a6, letter, card (85x55 mm) or a custom size in millimeters (like \"90x50\").")
+
+	pdfCaption := sp.flagSet.BoolP("caption", "a", false, "Whether a PDF QR code gets a caption with the name, job title and organization of the contact.")
+
+	terminal := sp.flagSet.BoolP("terminal", "e", false, "Print the QR code to the terminal instead of writing a QR code file.")
+
+	ascii := sp.flagSet.BoolP("ascii", "x", false, "Draw the QR code in the terminal with plain ASCII characters instead of Unicode half blocks.")
+
+	convert := sp.flagSet.Bool("convert", false, "Convert the vCards of the input into the vCard version of the cardversion flag, without asking for input and without QR codes.\nWhen writing to stdout, the converted vCard is written. The input can be of vCard version 2.1, 3.0 or 4.0. Fields that the target version does not know are left out and reported.")
+
+	vCardVersion := sp.flagSet.StringP("cardversion", "c", config.VCardVersion30, "The vCard version to create, one of "+strings.Join(config.VCardVersions, ", ")+".")
 
 	foregroundColor := sp.flagSet.StringP("foreground", "f", "black", "The foreground color of the QR code. This can be a hex RGB color value (like \"#000\") or a CSS color name (like black).")
 
@@ -63,6 +157,41 @@ func (sp *SettingsProvider) Load() (CLIFileSettings, error) {
 
 	size := sp.flagSet.IntP("size", "z", 400, "The size of the resulting QR code in width and height of pixels.")
 
+	logoPath := sp.flagSet.StringP("logo", "g", "", "The path and name of a PNG or JPEG image that is placed in the center of the QR code.\nThe recovery level is raised when necessary to keep the QR code readable.")
+
+	logoSize := sp.flagSet.Int("logo-size", 20, "The width of the logo in percent of the QR code width.")
+
+	recoveryLevel := sp.flagSet.StringP("recovery", "l", "low", "The error recovery level of the QR code, one of "+strings.Join(config.RecoveryLevelNames, ", ")+".\nA higher level makes the QR code more robust against damage, but also denser. Use auto to pick the highest level that still fits the vCard into the QR code.")
+
+	maxVersion := sp.flagSet.Int("max-version", config.MaxQRCodeVersion, fmt.Sprintf("The largest QR code version to create, between 1 and %d. A lower version gives fewer and larger modules, which print more reliably on small cards.", config.MaxQRCodeVersion))
+
+	trimOrder := sp.flagSet.StringSlice("trim-order", config.DefaultTrimOrder, "The fields that are left out of the QR code, one after the other, when the card does not fit into the max-version.\nParts of fields can be given like ADR.pobox, ADR.extended, ADR.region, ADR.country, N.prefix, N.additional, N.suffix and ORG.unit.\nThe vCard file always keeps all fields. Use an empty value to never leave out a field.")
+
+	verify := sp.flagSet.Bool("verify", false, "Read each QR code back after creating it and fail when it does not give the same content,\nor when the foreground is lighter than the background, or when the colors have less contrast than the min-contrast.")
+
+	minContrast := sp.flagSet.Float64("min-contrast", 3, "The smallest contrast ratio of the foreground and background colors that the verify flag accepts, between 1 and 21.\nThe contrast ratio is calculated as defined by WCAG, a transparent background counts as white.")
+
+	info := sp.flagSet.String("info", "", "Show the version, modules, recovery level, mask pattern and payload size of the QR code, and the smallest size to print it at the dpi.\nUse --info=json to get the same information as JSON, together with -s to get nothing else.")
+	sp.flagSet.Lookup("info").NoOptDefVal = config.InfoText
+
+	dpi := sp.flagSet.Int("dpi", 300, "The printer resolution in dots per inch, which is used by the info flag for the smallest print size of the QR code.")
+
+	region := sp.flagSet.String("region", "", "The two letter country code (like DE or US) of phone numbers without country code and of addresses without country.\nPhone numbers, mail addresses, web addresses and postal codes are checked before anything is written, a card with an invalid value fails.\nNational phone numbers and postal codes without country can not be checked without a region and are only reported.")
+
+	e164 := sp.flagSet.Bool("e164", false, "Rewrite the phone numbers of the cards into the international E.164 format, like +491711234567, and report every changed number.\nNational phone numbers are read for the region. A vCard 4.0 writes the phone numbers as tel: URIs.")
+
+	nameOrder := sp.flagSet.String("name-order", config.NameOrderGivenFirst, "The order of the name components in the formatted name (FN), which is taken from the name when a card has none, one of "+strings.Join(config.NameOrders, ", ")+".")
+
+	keepEmpty := sp.flagSet.Bool("keep-empty", false, "Keep the fields without value, like an empty ADR or GENDER, in the vCard file and the QR code, and add empty N, ADR, ORG, TEL and GENDER fields\nfor tools that need the full skeleton of a card. Without the flag, fields without value are left out to save space in the QR code.")
+
+	payload := sp.flagSet.String("payload", config.PayloadVCard, "The content of the QR code, one of "+strings.Join(config.Payloads, ", ")+".\nA mecard is much shorter than a vcard and gives a smaller QR code, but it can not carry all fields. Use auto to pick the payload that gives the smallest QR code.")
+
+	sp.flagSet.String(profileFlag, "", "The name of a profile from the config files, which sets a group of flags at once, like the colors, size, border, logo and format of a brand.\nProfiles are defined in the config files below the key "+profilesKey+". Flags that are given on the command line win over the profile.")
+
+	listProfiles := sp.flagSet.Bool("list-profiles", false, "List the profiles of the config files together with their settings.")
+
+	printConfig := sp.flagSet.Bool("print-config", false, "Show the merged settings and where each value came from, which is a flag, a QRVC_ environment variable (like QRVC_LOGO_SIZE),\nthe project file "+ProjectFileName+" in the current folder, the user file qrvc/config.yaml in the user config folder, or the default.\nThe config files are YAML files with the flag names as keys, like \"foreground: navy\", or TOML files of the same names ending in .toml, like \"foreground = 'navy'\".")
+
 	bom := sp.flagSet.BoolP("bom", "m", false, "List the Software Bill of Materials of this tool in CycloneDX format.")
 
 	appVersion := sp.flagSet.BoolP("version", "v", false, "Show the qrvc version.")
@@ -70,6 +199,11 @@ func (sp *SettingsProvider) Load() (CLIFileSettings, error) {
 	sp.formatFlagUsage()          //adjust help format before parsing
 	sp.flagSet.Parse(os.Args[1:]) //process flags
 
+	configValues, profiles, err := sp.applySources() //fill the flags that have not been given from config files and environment
+	if err != nil {
+		return CLIFileSettings{}, err
+	}
+
 	settings := CLIFileSettings{}
 	settings.App = config.Settings{}
 	settings.App.QRSettings = config.QRCodeSettings{}
@@ -84,19 +218,126 @@ func (sp *SettingsProvider) Load() (CLIFileSettings, error) {
 		return CLIFileSettings{}, errors.New("You must provide an input file when running in silent mode")
 	}
 
+	settings.App.Convert = *convert
+	if settings.App.Convert && settings.Files.ReadVCardPath == "" {
+		return CLIFileSettings{}, errors.New("You must provide an input to convert")
+	}
+
+	toStdout := *writePath == StandardStream
+	if toStdout {
+		//keep stdout clean for the output
+		sp.userNotifier.SetOutput(os.Stderr)
+	}
+
+	settings.Files.Batch = *batch || strings.EqualFold(filepath.Ext(settings.Files.ReadVCardPath), ".csv")
+	if settings.Files.Batch {
+		if settings.Files.ReadVCardPath == "" {
+			return CLIFileSettings{}, errors.New("You must provide an input folder, pattern or file when running in batch mode")
+		}
+		if toStdout {
+			return CLIFileSettings{}, errors.New("The batch mode writes into a folder and can not write to stdout")
+		}
+		settings.Files.WriteDirPath = *writePath
+		if settings.Files.WriteDirPath == "" {
+			settings.Files.WriteDirPath = "."
+		}
+	}
+	if settings.Files.ReadVCardPath == StandardStream && !settings.App.Silent && !settings.App.Convert && !settings.Files.Batch {
+		//the editor would read its input from the stdin that has been read already
+		return CLIFileSettings{}, errors.New("The editor can not be used when reading the vCard from stdin, use --silent")
+	}
+	settings.Files.NameTemplate = *nameTemplate
+	settings.Files.CSVMappings = *csvMappings
+	settings.Files.CSVMappingPath = *csvMappingPath
+
+	if *jobs < 1 {
+		return CLIFileSettings{}, fmt.Errorf("Invalid number of jobs %d, use at least 1", *jobs)
+	}
+	settings.App.Jobs = *jobs
+
+	settings.App.QRSettings.Verify = *verify
+	if *minContrast < 1 || *minContrast > 21 {
+		return CLIFileSettings{}, fmt.Errorf("Invalid contrast ratio %g, use a ratio between 1 and 21", *minContrast)
+	}
+	settings.App.QRSettings.MinContrast = *minContrast
+
+	settings.App.Info = strings.ToLower(*info)
+	if settings.App.Info != "" && !slices.Contains(config.InfoFormats, settings.App.Info) {
+		return CLIFileSettings{}, fmt.Errorf("Unknown info format %s, use one of %s", *info, strings.Join(config.InfoFormats, ", "))
+	}
+	if settings.App.Info != "" && settings.Files.Batch {
+		return CLIFileSettings{}, errors.New("The info describes a single QR code and can not be shown in batch mode")
+	}
+	if *dpi <= 0 {
+		return CLIFileSettings{}, fmt.Errorf("Invalid resolution %d, use a positive number of dots per inch", *dpi)
+	}
+	settings.App.DPI = *dpi
+
+	settings.App.Region = strings.ToUpper(*region)
+	if settings.App.Region != "" && !qrcard.KnownRegion(settings.App.Region) {
+		return CLIFileSettings{}, fmt.Errorf("Unknown region %s, use a two letter country code like DE or US", *region)
+	}
+	settings.App.E164 = *e164
+
+	settings.App.NameOrder = strings.ToLower(*nameOrder)
+	if !slices.Contains(config.NameOrders, settings.App.NameOrder) {
+		return CLIFileSettings{}, fmt.Errorf("Unknown name order %s, use one of %s", *nameOrder, strings.Join(config.NameOrders, ", "))
+	}
+
 	//adjust names according to readVCard
-	if settings.Files.ReadVCardPath != "" && *writePath == "" {
+	qrCodeSuffix := ""
+	if settings.Files.ReadVCardPath != "" && settings.Files.ReadVCardPath != StandardStream && *writePath == "" {
 		base := filepath.Base(settings.Files.ReadVCardPath)       // "file.txt"
 		*writePath = strings.TrimSuffix(base, filepath.Ext(base)) // "file"
+		if slices.Contains(imageExtensions, strings.ToLower(filepath.Ext(base))) {
+			//the QR code of an image input is named file.qr.png, to not overwrite the image
+			qrCodeSuffix = ".qr"
+		}
 	}
 	if *writePath == "" {
 		*writePath = "vcard"
 	}
-	settings.Files.WriteQRCodePath = *writePath + ".png"
-	settings.Files.WriteVCardPath = *writePath + ".vcf"
+	settings.Files.QRCodeFormat = strings.ToLower(*qrCodeFormat)
+	if toStdout && (settings.Files.QRCodeFormat == FormatVCard || settings.App.Convert) {
+		//only the vCard goes to stdout
+		settings.Files.WriteVCardPath = StandardStream
+	} else if !slices.Contains(qrCodeFormats, settings.Files.QRCodeFormat) {
+		return CLIFileSettings{}, fmt.Errorf("Unknown QR code format %s, use one of %s", *qrCodeFormat, strings.Join(qrCodeFormats, ", "))
+	} else if toStdout {
+		//only the QR code goes to stdout
+		settings.Files.WriteQRCodePath = StandardStream
+	} else {
+		settings.Files.WriteQRCodePath = *writePath + qrCodeSuffix + "." + settings.Files.QRCodeFormat
+		settings.Files.WriteVCardPath = *writePath + ".vcf"
+	}
+	if !settings.Files.Batch && samePath(settings.Files.ReadVCardPath, settings.Files.WriteQRCodePath) {
+		return CLIFileSettings{}, fmt.Errorf("The QR code would overwrite the input %s, choose another output with -o", settings.Files.ReadVCardPath)
+	}
 
+	if page, err := sp.parsePageSize(*pdfPage); err != nil {
+		return CLIFileSettings{}, err
+	} else {
+		settings.Files.PDFPage = page
+	}
+	settings.Files.PDFCaption = *pdfCaption
+
+	if !slices.Contains(config.VCardVersions, *vCardVersion) {
+		return CLIFileSettings{}, fmt.Errorf("Unknown vCard version %s, use one of %s", *vCardVersion, strings.Join(config.VCardVersions, ", "))
+	}
 	settings.App.VCardVersion = *vCardVersion
 
+	settings.App.Terminal = *terminal
+	if settings.App.Info != "" && (settings.App.Convert || !settings.App.Terminal && settings.Files.WriteQRCodePath == "") {
+		return CLIFileSettings{}, errors.New("The info describes the QR code, which is not written when only the vCard is written")
+	}
+	if settings.App.Terminal && toStdout {
+		return CLIFileSettings{}, errors.New("The QR code can either be printed to the terminal or written to stdout, but not both")
+	}
+	settings.App.TerminalStyle = config.TerminalUnicode
+	if *ascii {
+		settings.App.TerminalStyle = config.TerminalASCII
+	}
+
 	settings.App.QRSettings.Border = *border
 	settings.App.QRSettings.Size = *size
 
@@ -112,10 +353,45 @@ func (sp *SettingsProvider) Load() (CLIFileSettings, error) {
 		settings.App.QRSettings.BackgroundColor = color
 	}
 
-	settings.App.QRSettings.RecoveryLevel = qrcode.Low
+	*recoveryLevel = strings.ToLower(*recoveryLevel)
+	if *recoveryLevel == recoveryAuto {
+		settings.App.QRSettings.AutoRecoveryLevel = true
+		settings.App.QRSettings.RecoveryLevel = qrcode.Highest
+	} else if level := slices.Index(config.RecoveryLevelNames, *recoveryLevel); level >= 0 {
+		settings.App.QRSettings.RecoveryLevel = qrcode.RecoveryLevel(level)
+	} else {
+		return CLIFileSettings{}, fmt.Errorf("Unknown recovery level %s, use one of %s or %s", *recoveryLevel, strings.Join(config.RecoveryLevelNames, ", "), recoveryAuto)
+	}
+	if *maxVersion < 1 || *maxVersion > config.MaxQRCodeVersion {
+		return CLIFileSettings{}, fmt.Errorf("Invalid QR code version %d, use a version between 1 and %d", *maxVersion, config.MaxQRCodeVersion)
+	}
+	settings.App.QRSettings.MaxVersion = *maxVersion
+
+	if trimOrder, err := sp.parseTrimOrder(*trimOrder); err != nil {
+		return CLIFileSettings{}, err
+	} else {
+		settings.App.QRSettings.TrimOrder = trimOrder
+	}
+
+	settings.App.KeepEmptyFields = *keepEmpty
+
+	settings.App.QRSettings.Payload = strings.ToLower(*payload)
+	if !slices.Contains(config.Payloads, settings.App.QRSettings.Payload) {
+		return CLIFileSettings{}, fmt.Errorf("Unknown payload %s, use one of %s", *payload, strings.Join(config.Payloads, ", "))
+	}
+
+	settings.Files.LogoPath = *logoPath
+	if *logoSize <= 0 || *logoSize >= 100 {
+		return CLIFileSettings{}, fmt.Errorf("Invalid logo size %d, use a percentage between 1 and 99", *logoSize)
+	}
+	settings.App.QRSettings.LogoSize = float64(*logoSize) / 100
 
 	settings.CLI.Bom = *bom
 	settings.CLI.AppVersion = *appVersion
+	settings.CLI.PrintConfig = *printConfig
+	settings.CLI.ConfigValues = configValues
+	settings.CLI.ListProfiles = *listProfiles
+	settings.CLI.Profiles = profiles
 
 	return settings, nil
 }
@@ -161,3 +437,63 @@ func (sp *SettingsProvider) parseColor(color string) (color.Color, error) {
 		return c, nil
 	}
 }
+
+func (sp *SettingsProvider) parsePageSize(page string) (PageSize, error) {
+	page = strings.ToLower(strings.TrimSpace(page))
+	if size, ok := pageSizes[page]; ok {
+		return size, nil
+	}
+
+	invalid := fmt.Errorf("Invalid page size %s, use a4, a5, a6, letter, card or a size in millimeters like 90x50", page)
+
+	width, height, found := strings.Cut(page, "x")
+	if !found {
+		return PageSize{}, invalid
+	}
+	w, err := strconv.ParseFloat(strings.TrimSpace(width), 64)
+	if err != nil || w <= 0 {
+		return PageSize{}, invalid
+	}
+	h, err := strconv.ParseFloat(strings.TrimSpace(height), 64)
+	if err != nil || h <= 0 {
+		return PageSize{}, invalid
+	}
+	return PageSize{Width: w, Height: h}, nil
+}
+
+// parseTrimOrder brings the fields of the trim order into the form of vCard, like ADR.pobox, and rejects unknown parts of fields.
+func (sp *SettingsProvider) parseTrimOrder(items []string) ([]string, error) {
+	trimOrder := []string{}
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+
+		key, component, isComponent := strings.Cut(item, ".")
+		key = strings.ToUpper(key)
+		if !fieldName.MatchString(key) || key == "VERSION" {
+			return nil, fmt.Errorf("Invalid field %s in the trim order", item)
+		}
+		if !isComponent {
+			trimOrder = append(trimOrder, key)
+			continue
+		}
+
+		component = strings.ToLower(component)
+		if _, ok := config.TrimComponents[key][component]; !ok {
+			return nil, fmt.Errorf("Unknown part %s of field %s in the trim order", component, key)
+		}
+		trimOrder = append(trimOrder, key+"."+component)
+	}
+	return trimOrder, nil
+}
+
+// samePath tells whether both paths name the same file, the path - of the standard streams names no file.
+func samePath(a, b string) bool {
+	if a == "" || b == "" || a == StandardStream || b == StandardStream {
+		return false
+	}
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
@@ -1,14 +1,18 @@
 package configcli_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/mazznoer/csscolorparser"
 	"github.com/skip2/go-qrcode"
 	"github.com/stretchr/testify/assert"
 	configcli "github.com/ulfschneider/qrvc/internal/adapters/config/cli"
+	notifiercli "github.com/ulfschneider/qrvc/internal/adapters/notifier/cli"
 	testutil "github.com/ulfschneider/qrvc/internal/test/util"
 
+	"github.com/ulfschneider/qrvc/internal/application/config"
 	"github.com/ulfschneider/qrvc/internal/application/services"
 )
 
@@ -16,6 +20,8 @@ func TestDefaultSettings(t *testing.T) {
 
 	versionService := services.NewVersionService(testutil.CreateVersionProvider())
 	settingsProvider := configcli.NewSettingsProvider(versionService)
+	//the settings of the user running the tests are left out
+	settingsProvider.SetUserConfigDir(t.TempDir())
 
 	settings, err := settingsProvider.Load()
 	assert.NoError(t, err)
@@ -29,6 +35,12 @@ func TestDefaultSettings(t *testing.T) {
 
 	assert.Equal(t, "vcard.png", settings.Files.WriteQRCodePath)
 
+	assert.Equal(t, configcli.FormatPNG, settings.Files.QRCodeFormat)
+
+	assert.Equal(t, configcli.PageSize{Width: 210, Height: 297}, settings.Files.PDFPage)
+
+	assert.False(t, settings.Files.PDFCaption)
+
 	assert.False(t, settings.CLI.Bom)
 
 	//test application settings
@@ -51,4 +63,387 @@ func TestDefaultSettings(t *testing.T) {
 
 	assert.Equal(t, qrcode.Low, settings.App.QRSettings.RecoveryLevel)
 
+	assert.False(t, settings.App.QRSettings.AutoRecoveryLevel)
+
+	assert.Equal(t, 40, settings.App.QRSettings.MaxVersion)
+
+	assert.Equal(t, "", settings.Files.LogoPath)
+
+	assert.Nil(t, settings.App.QRSettings.Logo)
+
+	assert.Equal(t, 0.2, settings.App.QRSettings.LogoSize)
+
+	assert.Empty(t, settings.Files.CSVMappings)
+
+	assert.Equal(t, "", settings.Files.CSVMappingPath)
+
+}
+
+// loadSettings loads the settings of the arguments, leaving out the user file of the user running the tests.
+func loadSettings(t *testing.T, args ...string) (configcli.CLIFileSettings, error) {
+	return loadSettingsFrom(t, t.TempDir(), args...)
+}
+
+// loadSettingsFrom loads the settings of the arguments with the user file qrvc/config.yaml of the user config folder.
+func loadSettingsFrom(t *testing.T, userConfigDir string, args ...string) (configcli.CLIFileSettings, error) {
+	osArgs := os.Args
+	os.Args = append([]string{"qrvc"}, args...)
+	t.Cleanup(func() {
+		os.Args = osArgs
+		notifier := notifiercli.NewUserNotifier()
+		notifier.SetOutput(os.Stdout)
+	})
+
+	versionService := services.NewVersionService(testutil.CreateVersionProvider())
+	settingsProvider := configcli.NewSettingsProvider(versionService)
+	settingsProvider.SetUserConfigDir(userConfigDir)
+	return settingsProvider.Load()
+}
+
+func TestStandardStreamSettings(t *testing.T) {
+	settings, err := loadSettings(t, "-s", "-i", "-", "-o", "-", "-t", "svg")
+	assert.NoError(t, err)
+	assert.Equal(t, "-", settings.Files.ReadVCardPath)
+	assert.Equal(t, "-", settings.Files.WriteQRCodePath)
+	assert.Equal(t, "", settings.Files.WriteVCardPath)
+	assert.True(t, settings.Files.WritesToStdout())
+
+	settings, err = loadSettings(t, "-s", "-i", "-", "-o", "-", "-t", "vcf")
+	assert.NoError(t, err)
+	assert.Equal(t, "", settings.Files.WriteQRCodePath)
+	assert.Equal(t, "-", settings.Files.WriteVCardPath)
+
+	//reading from stdin does not name the output files after the input
+	settings, err = loadSettings(t, "-s", "-i", "-")
+	assert.NoError(t, err)
+	assert.Equal(t, "vcard.png", settings.Files.WriteQRCodePath)
+	assert.Equal(t, "vcard.vcf", settings.Files.WriteVCardPath)
+	assert.False(t, settings.Files.WritesToStdout())
+
+	//the vCard format is only available for stdout
+	_, err = loadSettings(t, "-s", "-i", "-", "-t", "vcf")
+	assert.Error(t, err)
+
+	_, err = loadSettings(t, "-s", "-i", "-", "-o", "-", "-e")
+	assert.Error(t, err)
+
+	_, err = loadSettings(t, "-i", "team", "-n", "-o", "-")
+	assert.Error(t, err)
+
+	//the editor would read its input from the stdin that carries the vCard
+	_, err = loadSettings(t, "-i", "-", "-o", "-")
+	assert.EqualError(t, err, "The editor can not be used when reading the vCard from stdin, use --silent")
+	_, err = loadSettings(t, "--convert", "-i", "-", "-o", "-")
+	assert.NoError(t, err)
+}
+
+func TestVCardVersionSettings(t *testing.T) {
+	for _, version := range []string{"2.1", "3.0", "4.0"} {
+		settings, err := loadSettings(t, "-c", version)
+		assert.NoError(t, err)
+		assert.Equal(t, version, settings.App.VCardVersion)
+	}
+
+	_, err := loadSettings(t, "-c", "4")
+	assert.ErrorContains(t, err, "Unknown vCard version 4")
+}
+
+func TestConvertSettings(t *testing.T) {
+	settings, err := loadSettings(t, "--convert", "-c", "4.0", "-i", "-", "-o", "-")
+	assert.NoError(t, err)
+	assert.True(t, settings.App.Convert)
+	assert.Equal(t, configcli.StandardStream, settings.Files.WriteVCardPath)
+	assert.Equal(t, "", settings.Files.WriteQRCodePath)
+
+	_, err = loadSettings(t, "--convert")
+	assert.Error(t, err)
+}
+
+func TestPayloadSettings(t *testing.T) {
+	settings, err := loadSettings(t)
+	assert.NoError(t, err)
+	assert.Equal(t, config.PayloadVCard, settings.App.QRSettings.Payload)
+
+	settings, err = loadSettings(t, "--payload", "MeCard")
+	assert.NoError(t, err)
+	assert.Equal(t, config.PayloadMeCard, settings.App.QRSettings.Payload)
+
+	_, err = loadSettings(t, "--payload", "bizcard")
+	assert.ErrorContains(t, err, "Unknown payload bizcard")
+}
+
+func TestTrimSettings(t *testing.T) {
+	settings, err := loadSettings(t)
+	assert.NoError(t, err)
+	assert.Equal(t, config.MaxQRCodeVersion, settings.App.QRSettings.MaxVersion)
+	assert.Equal(t, config.DefaultTrimOrder, settings.App.QRSettings.TrimOrder)
+
+	settings, err = loadSettings(t, "--max-version", "10", "--trim-order", "note,adr.POBox, title")
+	assert.NoError(t, err)
+	assert.Equal(t, 10, settings.App.QRSettings.MaxVersion)
+	assert.Equal(t, []string{"NOTE", "ADR.pobox", "TITLE"}, settings.App.QRSettings.TrimOrder)
+
+	settings, err = loadSettings(t, "--trim-order", "")
+	assert.NoError(t, err)
+	assert.Empty(t, settings.App.QRSettings.TrimOrder)
+
+	_, err = loadSettings(t, "--max-version", "41")
+	assert.Error(t, err)
+	_, err = loadSettings(t, "--trim-order", "ADR.floor")
+	assert.ErrorContains(t, err, "Unknown part floor of field ADR")
+	_, err = loadSettings(t, "--trim-order", "VERSION")
+	assert.Error(t, err)
+}
+
+func TestImageInputSettings(t *testing.T) {
+	//the QR code of an image input does not overwrite the image
+	settings, err := loadSettings(t, "-s", "-i", "colleague.png")
+	assert.NoError(t, err)
+	assert.Equal(t, "colleague.qr.png", settings.Files.WriteQRCodePath)
+	assert.Equal(t, "colleague.vcf", settings.Files.WriteVCardPath)
+
+	settings, err = loadSettings(t, "-s", "-i", "scans/colleague.JPG", "-t", "svg")
+	assert.NoError(t, err)
+	assert.Equal(t, "colleague.qr.svg", settings.Files.WriteQRCodePath)
+
+	//an output that names the input is refused
+	_, err = loadSettings(t, "-s", "-i", "colleague.png", "-o", "colleague")
+	assert.ErrorContains(t, err, "overwrite the input colleague.png")
+	_, err = loadSettings(t, "-s", "-i", "scans/colleague.png", "-o", "scans/../scans/colleague")
+	assert.Error(t, err)
+	_, err = loadSettings(t, "-s", "-i", "colleague.png", "-o", "colleague", "-t", "svg")
+	assert.NoError(t, err)
+}
+
+func TestInfoSettings(t *testing.T) {
+	settings, err := loadSettings(t)
+	assert.NoError(t, err)
+	assert.Equal(t, "", settings.App.Info)
+	assert.Equal(t, 300, settings.App.DPI)
+
+	settings, err = loadSettings(t, "--info", "--dpi", "600")
+	assert.NoError(t, err)
+	assert.Equal(t, config.InfoText, settings.App.Info)
+	assert.Equal(t, 600, settings.App.DPI)
+
+	settings, err = loadSettings(t, "--info=json")
+	assert.NoError(t, err)
+	assert.Equal(t, config.InfoJSON, settings.App.Info)
+
+	_, err = loadSettings(t, "--info=xml")
+	assert.Error(t, err)
+	_, err = loadSettings(t, "--dpi", "0")
+	assert.Error(t, err)
+	_, err = loadSettings(t, "--info", "-n", "-i", "team")
+	assert.Error(t, err)
+
+	//there is no QR code to describe when only the vCard is written
+	_, err = loadSettings(t, "--info", "-s", "-i", "-", "-o", "-", "-t", "vcf")
+	assert.Error(t, err)
+	_, err = loadSettings(t, "--info", "--convert", "-i", "contact.vcf")
+	assert.Error(t, err)
+	_, err = loadSettings(t, "--info", "-e")
+	assert.NoError(t, err)
+}
+
+func TestVerifySettings(t *testing.T) {
+	settings, err := loadSettings(t)
+	assert.NoError(t, err)
+	assert.False(t, settings.App.QRSettings.Verify)
+	assert.Equal(t, 3.0, settings.App.QRSettings.MinContrast)
+
+	settings, err = loadSettings(t, "--verify", "--min-contrast", "4.5")
+	assert.NoError(t, err)
+	assert.True(t, settings.App.QRSettings.Verify)
+	assert.Equal(t, 4.5, settings.App.QRSettings.MinContrast)
+
+	_, err = loadSettings(t, "--min-contrast", "0.5")
+	assert.Error(t, err)
+}
+
+func TestRegionSettings(t *testing.T) {
+	settings, err := loadSettings(t)
+	assert.NoError(t, err)
+	assert.Equal(t, "", settings.App.Region)
+
+	settings, err = loadSettings(t, "--region", "de")
+	assert.NoError(t, err)
+	assert.Equal(t, "DE", settings.App.Region)
+
+	_, err = loadSettings(t, "--region", "XY")
+	assert.Error(t, err)
+
+	assert.False(t, settings.App.E164)
+	settings, err = loadSettings(t, "--e164", "--region", "DE")
+	assert.NoError(t, err)
+	assert.True(t, settings.App.E164)
+}
+
+func TestKeepEmptySettings(t *testing.T) {
+	settings, err := loadSettings(t)
+	assert.NoError(t, err)
+	assert.False(t, settings.App.KeepEmptyFields)
+
+	settings, err = loadSettings(t, "--keep-empty")
+	assert.NoError(t, err)
+	assert.True(t, settings.App.KeepEmptyFields)
+}
+
+func TestNameOrderSettings(t *testing.T) {
+	settings, err := loadSettings(t)
+	assert.NoError(t, err)
+	assert.Equal(t, config.NameOrderGivenFirst, settings.App.NameOrder)
+
+	settings, err = loadSettings(t, "--name-order", "family-first")
+	assert.NoError(t, err)
+	assert.Equal(t, config.NameOrderFamilyFirst, settings.App.NameOrder)
+
+	_, err = loadSettings(t, "--name-order", "random")
+	assert.Error(t, err)
+}
+
+func TestConfigSources(t *testing.T) {
+	userDir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(userDir, "qrvc"), 0755))
+	userFile := filepath.Join(userDir, "qrvc", "config.yaml")
+	assert.NoError(t, os.WriteFile(userFile, []byte("foreground: navy\nbackground: yellow\nsize: 600\nborder: true\ncardversion: \"4.0\"\n"), 0644))
+
+	t.Chdir(t.TempDir())
+	assert.NoError(t, os.WriteFile(configcli.ProjectFileName, []byte("background: white\nsize: 500\ncsv-map:\n  - Mobile=TEL;TYPE=cell\n  - Company=ORG\n"), 0644))
+
+	t.Setenv("QRVC_SIZE", "300")
+	t.Setenv("QRVC_LOGO_SIZE", "10")
+
+	settings, err := loadSettingsFrom(t, userDir, "--logo-size", "15")
+	assert.NoError(t, err)
+
+	navy, _ := csscolorparser.Parse("navy")
+	white, _ := csscolorparser.Parse("white")
+	assert.Equal(t, navy, settings.App.QRSettings.ForegroundColor)
+	assert.Equal(t, white, settings.App.QRSettings.BackgroundColor)
+	assert.True(t, settings.App.QRSettings.Border)
+	assert.Equal(t, "4.0", settings.App.VCardVersion)
+	assert.Equal(t, 300, settings.App.QRSettings.Size)
+	assert.Equal(t, 0.15, settings.App.QRSettings.LogoSize)
+	assert.Equal(t, []string{"Mobile=TEL;TYPE=cell", "Company=ORG"}, settings.Files.CSVMappings)
+
+	sources := map[string]configcli.ConfigValue{}
+	for _, value := range settings.CLI.ConfigValues {
+		sources[value.Name] = value
+	}
+	assert.Equal(t, configcli.ConfigValue{Name: "foreground", Value: "navy", Source: configcli.SourceUserFile, Origin: userFile}, sources["foreground"])
+	assert.Equal(t, configcli.ConfigValue{Name: "background", Value: "white", Source: configcli.SourceProjectFile, Origin: configcli.ProjectFileName}, sources["background"])
+	assert.Equal(t, configcli.ConfigValue{Name: "size", Value: "300", Source: configcli.SourceEnv, Origin: "QRVC_SIZE"}, sources["size"])
+	assert.Equal(t, configcli.ConfigValue{Name: "logo-size", Value: "15", Source: configcli.SourceFlag}, sources["logo-size"])
+	assert.Equal(t, configcli.ConfigValue{Name: "format", Value: "png", Source: configcli.SourceDefault}, sources["format"])
+
+	//unknown settings and invalid values are reported
+	assert.NoError(t, os.WriteFile(configcli.ProjectFileName, []byte("colour: red\n"), 0644))
+	_, err = loadSettingsFrom(t, userDir)
+	assert.ErrorContains(t, err, "colour")
+
+	assert.NoError(t, os.WriteFile(configcli.ProjectFileName, []byte("output: somewhere\n"), 0644))
+	_, err = loadSettingsFrom(t, userDir)
+	assert.ErrorContains(t, err, "output")
+
+	assert.NoError(t, os.WriteFile(configcli.ProjectFileName, []byte("border: sometimes\n"), 0644))
+	_, err = loadSettingsFrom(t, userDir)
+	assert.ErrorContains(t, err, "border")
+}
+
+func TestTOMLConfigSources(t *testing.T) {
+	userDir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(userDir, "qrvc"), 0755))
+	userFile := filepath.Join(userDir, "qrvc", "config.toml")
+	assert.NoError(t, os.WriteFile(userFile, []byte("foreground = \"navy\"\nsize = 600\nborder = true\ncardversion = \"4.0\"\n"), 0644))
+
+	t.Chdir(t.TempDir())
+	assert.NoError(t, os.WriteFile(".qrvc.toml", []byte("background = \"white\"\ncsv-map = [\"Mobile=TEL;TYPE=cell\", \"Company=ORG\"]\n\n[profiles.acme-print]\nformat = \"pdf\"\npage = \"card\"\n"), 0644))
+
+	settings, err := loadSettingsFrom(t, userDir, "--profile", "acme-print")
+	assert.NoError(t, err)
+
+	navy, _ := csscolorparser.Parse("navy")
+	white, _ := csscolorparser.Parse("white")
+	assert.Equal(t, navy, settings.App.QRSettings.ForegroundColor)
+	assert.Equal(t, white, settings.App.QRSettings.BackgroundColor)
+	assert.True(t, settings.App.QRSettings.Border)
+	assert.Equal(t, "4.0", settings.App.VCardVersion)
+	assert.Equal(t, 600, settings.App.QRSettings.Size)
+	assert.Equal(t, []string{"Mobile=TEL;TYPE=cell", "Company=ORG"}, settings.Files.CSVMappings)
+	assert.Equal(t, configcli.FormatPDF, settings.Files.QRCodeFormat)
+	assert.Equal(t, ".qrvc.toml", settings.CLI.Profiles[0].Origin)
+
+	sources := map[string]configcli.ConfigValue{}
+	for _, value := range settings.CLI.ConfigValues {
+		sources[value.Name] = value
+	}
+	assert.Equal(t, configcli.ConfigValue{Name: "foreground", Value: "navy", Source: configcli.SourceUserFile, Origin: userFile}, sources["foreground"])
+	assert.Equal(t, configcli.ConfigValue{Name: "background", Value: "white", Source: configcli.SourceProjectFile, Origin: ".qrvc.toml"}, sources["background"])
+
+	//a YAML and a TOML file in the same place are refused
+	assert.NoError(t, os.WriteFile(configcli.ProjectFileName, []byte("background: white\n"), 0644))
+	_, err = loadSettingsFrom(t, userDir)
+	assert.ErrorContains(t, err, "keep only one of them")
+	assert.NoError(t, os.Remove(configcli.ProjectFileName))
+
+	assert.NoError(t, os.WriteFile(".qrvc.toml", []byte("background: white\n"), 0644))
+	_, err = loadSettingsFrom(t, userDir)
+	assert.ErrorContains(t, err, "not valid TOML")
+}
+
+func TestProfiles(t *testing.T) {
+	userDir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(userDir, "qrvc"), 0755))
+	userFile := filepath.Join(userDir, "qrvc", "config.yaml")
+	assert.NoError(t, os.WriteFile(userFile, []byte("profiles:\n  acme-print:\n    format: png\n  acme-web:\n    format: svg\n    size: 800\n    foreground: navy\n"), 0644))
+
+	//the project file replaces the profile of the user file with the same name
+	t.Chdir(t.TempDir())
+	assert.NoError(t, os.WriteFile(configcli.ProjectFileName, []byte("border: true\nprofiles:\n  acme-print:\n    format: pdf\n    page: card\n    border: false\n"), 0644))
+
+	t.Setenv("QRVC_SIZE", "300")
+
+	settings, err := loadSettingsFrom(t, userDir)
+	assert.NoError(t, err)
+	assert.Len(t, settings.CLI.Profiles, 2)
+	assert.Equal(t, "acme-print", settings.CLI.Profiles[0].Name)
+	assert.Equal(t, configcli.ProjectFileName, settings.CLI.Profiles[0].Origin)
+	assert.Equal(t, []configcli.ConfigValue{
+		{Name: "border", Value: "false", Source: configcli.SourceProfile, Origin: configcli.ProjectFileName},
+		{Name: "format", Value: "pdf", Source: configcli.SourceProfile, Origin: configcli.ProjectFileName},
+		{Name: "page", Value: "card", Source: configcli.SourceProfile, Origin: configcli.ProjectFileName},
+	}, settings.CLI.Profiles[0].Settings)
+	assert.Equal(t, "acme-web", settings.CLI.Profiles[1].Name)
+
+	//without a profile
+	assert.Equal(t, configcli.FormatPNG, settings.Files.QRCodeFormat)
+	assert.True(t, settings.App.QRSettings.Border)
+
+	//the profile wins over files and environment, but not over flags
+	settings, err = loadSettingsFrom(t, userDir, "--profile", "acme-print")
+	assert.NoError(t, err)
+	assert.Equal(t, configcli.FormatPDF, settings.Files.QRCodeFormat)
+	assert.Equal(t, configcli.PageSize{Width: 85, Height: 55}, settings.Files.PDFPage)
+	assert.False(t, settings.App.QRSettings.Border)
+
+	settings, err = loadSettingsFrom(t, userDir, "--profile", "acme-web", "--size", "1000")
+	assert.NoError(t, err)
+	assert.Equal(t, configcli.FormatSVG, settings.Files.QRCodeFormat)
+	assert.Equal(t, 1000, settings.App.QRSettings.Size)
+	navy, _ := csscolorparser.Parse("navy")
+	assert.Equal(t, navy, settings.App.QRSettings.ForegroundColor)
+
+	//the profile can be selected by the environment
+	t.Setenv("QRVC_PROFILE", "acme-web")
+	settings, err = loadSettingsFrom(t, userDir)
+	assert.NoError(t, err)
+	assert.Equal(t, 800, settings.App.QRSettings.Size)
+
+	_, err = loadSettingsFrom(t, userDir, "--profile", "acme")
+	assert.ErrorContains(t, err, "acme-print, acme-web")
+
+	assert.NoError(t, os.WriteFile(configcli.ProjectFileName, []byte("profiles:\n  broken:\n    colour: red\n"), 0644))
+	_, err = loadSettingsFrom(t, userDir, "--profile", "broken")
+	assert.ErrorContains(t, err, "colour")
 }
@@ -0,0 +1,282 @@
+package configcli
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// The places a setting can come from, from the lowest to the highest precedence.
+const (
+	SourceDefault     = "default"
+	SourceUserFile    = "user file"
+	SourceProjectFile = "project file"
+	SourceEnv         = "environment"
+	SourceProfile     = "profile"
+	SourceFlag        = "flag"
+)
+
+// profilesKey holds the named profiles in a config file.
+const profilesKey = "profiles"
+
+// profileFlag selects a profile.
+const profileFlag = "profile"
+
+// ProjectFileName is the name of the config file in the current folder, which can as well be the TOML file .qrvc.toml.
+const ProjectFileName = ".qrvc.yaml"
+
+// tomlExtension marks a config file in TOML format instead of YAML.
+const tomlExtension = ".toml"
+
+// envPrefix is put in front of the upper case flag name to get the environment variable of a setting, like QRVC_LOGO_SIZE.
+const envPrefix = "QRVC_"
+
+// unconfigurableFlags describe a single run and are only accepted on the command line.
+var unconfigurableFlags = []string{"input", "output", "batch", "print-config", "list-profiles", "convert", "bom", "version"}
+
+// ConfigValue is the value of a flag after merging all sources, together with the source it came from.
+type ConfigValue struct {
+	Name   string
+	Value  string
+	Source string
+	Origin string
+}
+
+// Profile is a named set of flag values from a config file, like the colors, size and logo of a brand.
+type Profile struct {
+	Name     string
+	Origin   string
+	Settings []ConfigValue
+	values   map[string]any
+}
+
+// configLayer are the flag values of a config file.
+type configLayer struct {
+	source string
+	origin string
+	values map[string]any
+}
+
+// userConfigPath returns the path of the YAML config file in the user config folder, or an empty path when there is no such folder.
+// The user file can as well be the TOML file next to it.
+func (sp *SettingsProvider) userConfigPath() string {
+	if sp.userConfigDir == "" {
+		return ""
+	}
+	return filepath.Join(sp.userConfigDir, "qrvc", "config.yaml")
+}
+
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// configFilePath returns the TOML file next to the YAML config file when only the TOML file exists, and the YAML file otherwise.
+// A YAML and a TOML file in the same place are refused, because it is unclear which of them counts.
+func configFilePath(yamlPath string) (string, error) {
+	tomlPath := strings.TrimSuffix(yamlPath, filepath.Ext(yamlPath)) + tomlExtension
+	_, yamlErr := os.Stat(yamlPath)
+	_, tomlErr := os.Stat(tomlPath)
+	if yamlErr == nil && tomlErr == nil {
+		return "", fmt.Errorf("The config files %s and %s are both there, keep only one of them", yamlPath, tomlPath)
+	} else if tomlErr == nil {
+		return tomlPath, nil
+	}
+	return yamlPath, nil
+}
+
+// readConfigFile reads the flag values of a YAML or TOML config file, a file that does not exist has no values.
+func readConfigFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return map[string]any{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	values := map[string]any{}
+	if strings.EqualFold(filepath.Ext(path), tomlExtension) {
+		if err := toml.Unmarshal(data, &values); err != nil {
+			return nil, fmt.Errorf("The config file %s is not valid TOML: %w", path, err)
+		}
+	} else if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("The config file %s is not valid YAML: %w", path, err)
+	}
+	return values, nil
+}
+
+// applySources sets all flags that have not been given on the command line from the selected profile, the environment and the config files.
+// The precedence is flags, profile, environment, project file, user file and defaults.
+func (sp *SettingsProvider) applySources() ([]ConfigValue, []Profile, error) {
+	userPath := sp.userConfigPath()
+
+	layers := []configLayer{}
+	profiles := map[string]Profile{}
+	for _, file := range []struct{ source, path string }{{SourceUserFile, userPath}, {SourceProjectFile, ProjectFileName}} {
+		if file.path == "" {
+			continue
+		}
+		path, err := configFilePath(file.path)
+		if err != nil {
+			return nil, nil, err
+		}
+		values, err := readConfigFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		fileProfiles, err := sp.readProfiles(values[profilesKey], path)
+		if err != nil {
+			return nil, nil, err
+		}
+		delete(values, profilesKey)
+		if err := sp.checkConfigKeys(values, path); err != nil {
+			return nil, nil, err
+		}
+
+		//the project file comes last and wins
+		layers = slices.Insert(layers, 0, configLayer{source: file.source, origin: path, values: values})
+		for _, profile := range fileProfiles {
+			profiles[profile.Name] = profile
+		}
+	}
+
+	//the profile is selected first, because it takes part in the values of all other flags
+	profileValue, err := sp.applySource(sp.flagSet.Lookup(profileFlag), nil, layers)
+	if err != nil {
+		return nil, nil, err
+	}
+	var profile *Profile
+	if name := profileValue.Value; name != "" {
+		selected, ok := profiles[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("Unknown profile %s, use one of %s", name, strings.Join(slices.Sorted(maps.Keys(profiles)), ", "))
+		}
+		profile = &selected
+	}
+
+	configValues := []ConfigValue{}
+	sp.flagSet.VisitAll(func(f *pflag.Flag) {
+		if err != nil || slices.Contains(unconfigurableFlags, f.Name) {
+			return
+		}
+		if f.Name == profileFlag {
+			configValues = append(configValues, profileValue)
+			return
+		}
+
+		var configValue ConfigValue
+		configValue, err = sp.applySource(f, profile, layers)
+		configValues = append(configValues, configValue)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return configValues, slices.SortedFunc(maps.Values(profiles), func(a, b Profile) int { return strings.Compare(a.Name, b.Name) }), nil
+}
+
+// applySource sets a flag that has not been given on the command line from the first source that has a value for it.
+func (sp *SettingsProvider) applySource(f *pflag.Flag, profile *Profile, layers []configLayer) (ConfigValue, error) {
+	configValue := ConfigValue{Name: f.Name, Source: SourceDefault}
+	var err error
+
+	if f.Changed {
+		configValue.Source = SourceFlag
+	} else if value, ok := profileValue(profile, f.Name); ok {
+		configValue.Source, configValue.Origin = SourceProfile, profile.Name+" in "+profile.Origin
+		err = setFlag(f, configStrings(value), configValue.Origin)
+	} else if value, ok := os.LookupEnv(envName(f.Name)); ok {
+		configValue.Source, configValue.Origin = SourceEnv, envName(f.Name)
+		err = setFlag(f, []string{value}, envName(f.Name))
+	} else {
+		for _, layer := range layers {
+			if value, ok := layer.values[f.Name]; ok {
+				configValue.Source, configValue.Origin = layer.source, layer.origin
+				err = setFlag(f, configStrings(value), layer.origin)
+				break
+			}
+		}
+	}
+
+	configValue.Value = f.Value.String()
+	return configValue, err
+}
+
+func profileValue(profile *Profile, name string) (any, bool) {
+	if profile == nil {
+		return nil, false
+	}
+	value, ok := profile.values[name]
+	return value, ok
+}
+
+// readProfiles reads the profiles of a config file, each profile maps flag names to values like the config file itself.
+func (sp *SettingsProvider) readProfiles(value any, path string) ([]Profile, error) {
+	if value == nil {
+		return nil, nil
+	}
+	profileValues, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("The %s in the config file %s must map profile names to settings", profilesKey, path)
+	}
+
+	profiles := []Profile{}
+	for name, settings := range profileValues {
+		values, ok := settings.(map[string]any)
+		if !ok && settings != nil {
+			return nil, fmt.Errorf("The profile %s in the config file %s must map flag names to values", name, path)
+		}
+		if _, selectsProfile := values[profileFlag]; selectsProfile {
+			return nil, fmt.Errorf("The profile %s in the config file %s can not select another profile", name, path)
+		}
+		if err := sp.checkConfigKeys(values, path); err != nil {
+			return nil, err
+		}
+
+		profile := Profile{Name: name, Origin: path, values: values}
+		for _, key := range slices.Sorted(maps.Keys(values)) {
+			profile.Settings = append(profile.Settings, ConfigValue{Name: key, Value: strings.Join(configStrings(values[key]), ", "), Source: SourceProfile, Origin: path})
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+// checkConfigKeys rejects keys that are no flag, which are most likely typing errors.
+func (sp *SettingsProvider) checkConfigKeys(values map[string]any, path string) error {
+	for key := range values {
+		if sp.flagSet.Lookup(key) == nil || slices.Contains(unconfigurableFlags, key) {
+			return fmt.Errorf("Unknown setting %s in the config file %s", key, path)
+		}
+	}
+	return nil
+}
+
+// configStrings turns a YAML or TOML value into flag values, a list sets a repeatable flag once per item.
+func configStrings(value any) []string {
+	if list, ok := value.([]any); ok {
+		values := []string{}
+		for _, item := range list {
+			values = append(values, fmt.Sprint(item))
+		}
+		return values
+	} else if value == nil {
+		return []string{""}
+	}
+	return []string{fmt.Sprint(value)}
+}
+
+func setFlag(f *pflag.Flag, values []string, origin string) error {
+	for _, value := range values {
+		if err := f.Value.Set(value); err != nil {
+			return fmt.Errorf("Invalid value %s for %s in %s: %w", value, f.Name, origin, err)
+		}
+	}
+	return nil
+}
@@ -1,52 +1,146 @@
 package editorcli
 
 import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/huh"
 	"github.com/emersion/go-vcard"
+	"github.com/spf13/afero"
+	notifiercli "github.com/ulfschneider/qrvc/internal/adapters/notifier/cli"
+	"github.com/ulfschneider/qrvc/internal/application/config"
+	"github.com/ulfschneider/qrvc/internal/application/ports"
 	qrcard "github.com/ulfschneider/qrvc/internal/domain"
 )
 
 type CardEditor struct {
+	previewer    ports.QRCodePreviewer
+	qrCodec      ports.QRCodec
+	fileSystem   afero.Fs
+	settings     config.Settings
+	output       io.Writer
+	userNotifier notifiercli.UserNotifier
 }
 
-func NewCardEditor() CardEditor {
-	return CardEditor{}
+// NewCardEditor creates an editor that draws its forms to the output, which must be stderr when stdout carries the result.
+// The QR codec measures whether an embedded photo still fits into the QR code, local photos are read from the file system.
+func NewCardEditor(previewer ports.QRCodePreviewer, qrCodec ports.QRCodec, fileSystem afero.Fs, settings config.Settings, output io.Writer) CardEditor {
+	return CardEditor{previewer: previewer, qrCodec: qrCodec, fileSystem: fileSystem, settings: settings, output: output, userNotifier: notifiercli.NewUserNotifier()}
 }
 
 func (e *CardEditor) Edit(card vcard.Card) error {
-	formData := transferVCardIntoFormData(card)
+	familyFirst := e.settings.NameOrder == config.NameOrderFamilyFirst
+	formData := transferVCardIntoFormData(card, familyFirst)
 
 	for {
-		form := prepareForm(formData)
+		form := prepareForm(&formData, e.settings.Region, e.fileSystem).WithOutput(e.output)
 		if err := form.Run(); err != nil {
 			return err
 		}
-		if formData.ready {
+
+		//show the QR code of the current input before asking for confirmation
+		if err := transferFormDataIntoVCard(card, formData, e.fileSystem); err != nil {
+			return err
+		}
+		if e.settings.E164 {
+			for _, change := range qrcard.NormalizePhoneNumbers(card, e.settings.Region) {
+				e.userNotifier.Notifyf("The phone number %s has been changed to %s", change.From, change.To)
+			}
+		}
+		if !qrcard.FormattedNameAgrees(card) {
+			e.userNotifier.Notifyf("The formatted name %s does not agree with the name %s", card.Value(vcard.FieldFormattedName), qrcard.JoinName(card.Name(), familyFirst))
+		}
+		//reading the card back drops the entries that have been cleared
+		formData = transferVCardIntoFormData(card, familyFirst)
+		if formData.photo == embeddedPhoto && !e.qrCodec.Fits(card, e.settings.QRSettings) {
+			e.userNotifier.Section()
+			e.userNotifier.Notifyf("The card with the embedded photo does not fit into QR code version %s. Link the photo with a web address to keep it in the QR code.", config.EffectiveMaxVersion(e.settings.QRSettings))
+		}
+		if e.previewer != nil {
+			if err := e.previewer.Preview(card); err != nil {
+				//the input is kept to change what keeps the QR code from being made, like a card that is too large
+				e.userNotifier.Section()
+				e.userNotifier.Notify(err)
+				continue
+			}
+		}
+
+		confirmForm := prepareConfirmForm(&formData).WithOutput(e.output)
+		if err := confirmForm.Run(); err != nil {
+			return err
+		}
+		if formData.next == nextReady {
 			break
 		}
+		formData.add(formData.next)
 	}
 
-	transferFormDataIntoVCard(card, formData)
 	return nil
 }
 
 type qrCardFormData struct {
-	name         *vcard.Name
-	gender       vcard.Sex
-	title        string
-	organization string
-	department   string
-	address      *vcard.Address
-	email        string
-	url          string
-	cellPhone    string
-	workPhone    string
-	homePhone    string
-	ready        bool
+	version       string
+	name          *vcard.Name
+	formattedName string
+	familyFirst   bool
+	gender        vcard.Sex
+	title         string
+	organization  string
+	department    string
+	addresses     []*addressEntry
+	emails        []*typedEntry
+	urls          []*typedEntry
+	cellPhone     string
+	workPhone     string
+	homePhone     string
+	nickname      string
+	birthday      string
+	role          string
+	note          string
+	photo         string
+	next          string
+}
+
+// typedEntry is one of the repeatable values of the form, like a mail address. It holds the field of the card,
+// to write the value back with all of its parameters, and the kind of the entry, which is work, home or other.
+type typedEntry struct {
+	field *vcard.Field
+	value string
+	kind  string
+}
+
+// addressEntry is one of the postal addresses of the form, the address holds the field of the card.
+// The components of the address as they have been read tell whether the address has been changed.
+type addressEntry struct {
+	address  *vcard.Address
+	kind     string
+	original string
 }
 
+// typeOther is the kind of an entry that is neither for work nor for home, it is written without such a type.
+const typeOther = "other"
+
+// The steps to take after the form has been filled.
+const (
+	nextReady      = "ready"
+	nextEdit       = "edit"
+	nextAddEmail   = "email"
+	nextAddURL     = "url"
+	nextAddAddress = "address"
+)
+
+// embeddedPhoto stands for the embedded photo of a card in the form, it keeps the photo when it is not changed.
+const embeddedPhoto = "(embedded photo)"
+
+// birthdayFormat matches a date like 1990-05-17 or 19900517, or a date without year like --05-17 of vCard 4.0.
+var birthdayFormat = regexp.MustCompile(`^(\d{4}-?|--)\d{2}-?\d{2}$`)
+
 func maybeGet(s []string, i int) string {
 	if i < len(s) {
 		return s[i]
@@ -54,7 +148,9 @@ func maybeGet(s []string, i int) string {
 	return ""
 }
 
-func transferVCardIntoFormData(card vcard.Card) qrCardFormData {
+// transferVCardIntoFormData fills the form with the card. A formatted name that is derived from the name is left empty,
+// to derive it again from the edited name.
+func transferVCardIntoFormData(card vcard.Card, familyFirst bool) qrCardFormData {
 
 	sex, _ := card.Gender()
 
@@ -62,85 +158,462 @@ func transferVCardIntoFormData(card vcard.Card) qrCardFormData {
 	organization := maybeGet(orgSplit, 0)
 	department := maybeGet(orgSplit, 1)
 
+	name := card.Name()
+	if name == nil {
+		name = &vcard.Name{}
+	}
+
+	formattedName := card.Value(vcard.FieldFormattedName)
+	if formattedName == qrcard.JoinName(name, familyFirst) {
+		formattedName = ""
+	}
+
 	data := qrCardFormData{
-		name:         card.Name(),
-		gender:       sex,
-		title:        card.Value(vcard.FieldTitle),
-		organization: organization,
-		department:   department,
-		address:      card.Address(),
-		email:        card.Value(vcard.FieldEmail),
-		url:          card.Value(vcard.FieldURL),
-		cellPhone:    qrcard.TypedVcardFieldValue(card, vcard.FieldTelephone, vcard.TypeCell),
-		workPhone:    qrcard.TypedVcardFieldValue(card, vcard.FieldTelephone, vcard.TypeWork),
-		homePhone:    qrcard.TypedVcardFieldValue(card, vcard.FieldTelephone, vcard.TypeHome),
-		ready:        true,
+		version:       card.Value(vcard.FieldVersion),
+		name:          name,
+		formattedName: formattedName,
+		familyFirst:   familyFirst,
+		gender:        sex,
+		title:         card.Value(vcard.FieldTitle),
+		organization:  organization,
+		department:    department,
+		addresses:     addressEntries(card),
+		emails:        typedEntries(card, vcard.FieldEmail),
+		urls:          typedEntries(card, vcard.FieldURL),
+		cellPhone:     qrcard.TypedVcardFieldValue(card, vcard.FieldTelephone, vcard.TypeCell),
+		workPhone:     qrcard.TypedVcardFieldValue(card, vcard.FieldTelephone, vcard.TypeWork),
+		homePhone:     qrcard.TypedVcardFieldValue(card, vcard.FieldTelephone, vcard.TypeHome),
+		nickname:      card.Value(vcard.FieldNickname),
+		birthday:      card.Value(vcard.FieldBirthday),
+		role:          card.Value(vcard.FieldRole),
+		note:          card.Value(vcard.FieldNote),
+		photo:         photoFormValue(card.Get(vcard.FieldPhoto)),
+		next:          nextReady,
 	}
 
 	return data
 }
 
-func transferFormDataIntoVCard(card vcard.Card, formData qrCardFormData) {
-	card.SetName(formData.name)
-	card.SetGender(vcard.Sex(formData.gender), "")
-	card.SetValue(vcard.FieldTitle, formData.title)
-	card.SetValue(vcard.FieldOrganization, formData.organization+";"+formData.department)
-	card.SetAddress(formData.address)
-	card.SetValue(vcard.FieldEmail, formData.email)
-	card.SetValue(vcard.FieldURL, formData.url)
+// typedEntries returns an entry for each field of the given name, or one empty entry when the card has none of them.
+func typedEntries(card vcard.Card, fieldName string) []*typedEntry {
+	entries := []*typedEntry{}
+	for _, field := range card[fieldName] {
+		entries = append(entries, &typedEntry{field: field, value: field.Value, kind: entryKind(field.Params)})
+	}
+	if len(entries) == 0 {
+		entries = append(entries, &typedEntry{kind: typeOther})
+	}
+	return entries
+}
+
+// addressEntries returns an entry for each address of the card, or one empty entry when the card has no address.
+func addressEntries(card vcard.Card) []*addressEntry {
+	entries := []*addressEntry{}
+	for _, address := range card.Addresses() {
+		entries = append(entries, &addressEntry{address: address, kind: entryKind(address.Params), original: joinAddress(address)})
+	}
+	if len(entries) == 0 {
+		entries = append(entries, &addressEntry{address: &vcard.Address{}, kind: typeOther})
+	}
+	return entries
+}
+
+// joinAddress joins the components of the address in the order of the ADR field.
+func joinAddress(address *vcard.Address) string {
+	return strings.Join([]string{address.PostOfficeBox, address.ExtendedAddress, address.StreetAddress, address.Locality, address.Region, address.PostalCode, address.Country}, ";")
+}
+
+func entryKind(params vcard.Params) string {
+	switch {
+	case params.HasType(vcard.TypeWork):
+		return vcard.TypeWork
+	case params.HasType(vcard.TypeHome):
+		return vcard.TypeHome
+	default:
+		return typeOther
+	}
+}
+
+// add appends an empty entry for the next step, other steps leave the form data as it is.
+func (d *qrCardFormData) add(next string) {
+	switch next {
+	case nextAddEmail:
+		d.emails = append(d.emails, &typedEntry{kind: typeOther})
+	case nextAddURL:
+		d.urls = append(d.urls, &typedEntry{kind: typeOther})
+	case nextAddAddress:
+		d.addresses = append(d.addresses, &addressEntry{address: &vcard.Address{}, kind: typeOther})
+	}
+}
+
+// photoFormValue shows a linked photo by its web address, and an embedded photo by a placeholder.
+func photoFormValue(photo *vcard.Field) string {
+	if photo == nil || photo.Value == "" {
+		return ""
+	}
+	if isWebAddress(photo.Value) {
+		return photo.Value
+	}
+	return embeddedPhoto
+}
+
+func isWebAddress(value string) bool {
+	return strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://")
+}
+
+// transferFormDataIntoVCard writes the form into the card. Fields that have not been changed in the form are left untouched,
+// to write them back exactly as they have been read.
+func transferFormDataIntoVCard(card vcard.Card, formData qrCardFormData, fileSystem afero.Fs) error {
+	transferName(card, formData.name)
+	if formattedName := strings.TrimSpace(formData.formattedName); formattedName != "" {
+		setValue(card, vcard.FieldFormattedName, formattedName)
+	} else if formData.version != config.VCardVersion21 || card.Get(vcard.FieldFormattedName) != nil {
+		//FN is not required in vCard 2.1 and is only derived when the card has one
+		setOptionalValue(card, vcard.FieldFormattedName, qrcard.JoinName(formData.name, formData.familyFirst))
+	}
+	transferGender(card, formData.gender)
+	setValue(card, vcard.FieldTitle, formData.title)
+	transferOrganization(card, formData.organization, formData.department)
+	transferAddressEntries(card, formData.addresses)
+	transferTypedEntries(card, vcard.FieldEmail, formData.emails)
+	transferTypedEntries(card, vcard.FieldURL, formData.urls)
 	qrcard.SetTypedVcardFieldValue(card, vcard.FieldTelephone, vcard.TypeCell, formData.cellPhone)
 	qrcard.SetTypedVcardFieldValue(card, vcard.FieldTelephone, vcard.TypeWork, formData.workPhone)
 	qrcard.SetTypedVcardFieldValue(card, vcard.FieldTelephone, vcard.TypeHome, formData.homePhone)
+	setOptionalValue(card, vcard.FieldNickname, formData.nickname)
+	setOptionalValue(card, vcard.FieldBirthday, formData.birthday)
+	setOptionalValue(card, vcard.FieldRole, formData.role)
+	setOptionalValue(card, vcard.FieldNote, formData.note)
+
+	return transferPhoto(card, strings.TrimSpace(formData.photo), fileSystem)
 }
 
-func prepareForm(formData qrCardFormData) *huh.Form {
+// transferName writes the name when its components have been changed, or when the card gets a name it did not have.
+func transferName(card vcard.Card, name *vcard.Name) {
+	current := card.Name()
+	if current == nil {
+		if qrcard.JoinName(name, false) != "" {
+			card.SetName(name)
+		}
+		return
+	}
+	if current.FamilyName != name.FamilyName || current.GivenName != name.GivenName || current.AdditionalName != name.AdditionalName ||
+		current.HonorificPrefix != name.HonorificPrefix || current.HonorificSuffix != name.HonorificSuffix {
+		card.SetName(name)
+	}
+}
+
+// transferGender writes the sex when it has been changed, the gender identity of the card is kept. No sex removes the gender.
+func transferGender(card vcard.Card, sex vcard.Sex) {
+	current, identity := card.Gender()
+	switch {
+	case sex == current:
+		return
+	case sex == "" && identity == "":
+		delete(card, vcard.FieldGender)
+	case identity == "":
+		setValue(card, vcard.FieldGender, string(sex))
+	default:
+		setValue(card, vcard.FieldGender, string(sex)+";"+identity)
+	}
+}
+
+// transferOrganization writes the organization and the department when one of them has been changed.
+func transferOrganization(card vcard.Card, organization, department string) {
+	orgSplit := strings.SplitN(card.Value(vcard.FieldOrganization), ";", 2)
+	if organization == maybeGet(orgSplit, 0) && department == maybeGet(orgSplit, 1) {
+		return
+	}
+	if department == "" {
+		setValue(card, vcard.FieldOrganization, organization)
+	} else {
+		setValue(card, vcard.FieldOrganization, organization+";"+department)
+	}
+}
+
+// setValue sets the value of the first field of the given name, the parameters of the field are kept.
+// A field is only added when there is a value, and an equal value is not written again.
+func setValue(card vcard.Card, fieldName, value string) {
+	if field := card.Get(fieldName); field != nil {
+		if field.Value != value {
+			field.Value = value
+		}
+	} else if value != "" {
+		card.SetValue(fieldName, value)
+	}
+}
+
+// setOptionalValue sets the value of the field, or removes the field when the value is empty.
+// A value that only differs by surrounding white space is kept as it is.
+func setOptionalValue(card vcard.Card, fieldName, value string) {
+	if value = strings.TrimSpace(value); value == "" {
+		delete(card, fieldName)
+	} else if strings.TrimSpace(card.Value(fieldName)) != value {
+		setValue(card, fieldName, value)
+	}
+}
 
-	vCardForm := huh.NewForm(
+// transferTypedEntries replaces the fields of the given name with the entries that have a value, in their order.
+func transferTypedEntries(card vcard.Card, fieldName string, entries []*typedEntry) {
+	fields := []*vcard.Field{}
+	for _, entry := range entries {
+		value := strings.TrimSpace(entry.value)
+		if value == "" {
+			continue
+		}
+		if entry.field == nil {
+			entry.field = &vcard.Field{Params: vcard.Params{}}
+		}
+		if strings.TrimSpace(entry.field.Value) != value {
+			entry.field.Value = value
+		}
+		setKind(entry.field, entry.kind)
+		fields = append(fields, entry.field)
+	}
+	setFields(card, fieldName, fields)
+}
+
+// transferAddressEntries replaces the addresses of the card with the entries that are not empty, in their order.
+func transferAddressEntries(card vcard.Card, entries []*addressEntry) {
+	fields := []*vcard.Field{}
+	for _, entry := range entries {
+		address := entry.address
+		if strings.TrimSpace(address.PostOfficeBox+address.ExtendedAddress+address.StreetAddress+address.Locality+address.Region+address.PostalCode+address.Country) == "" {
+			continue
+		}
+		if address.Field == nil {
+			address.Field = &vcard.Field{Params: vcard.Params{}}
+		}
+		if joined := joinAddress(address); joined != entry.original {
+			address.Value = joined
+		}
+		setKind(address.Field, entry.kind)
+		fields = append(fields, address.Field)
+	}
+	setFields(card, vcard.FieldAddress, fields)
+}
+
+func setFields(card vcard.Card, fieldName string, fields []*vcard.Field) {
+	if len(fields) == 0 {
+		delete(card, fieldName)
+	} else {
+		card[fieldName] = fields
+	}
+}
+
+// setKind replaces the work or home type of the field with the kind, other types like pref are kept.
+// A field that is already of the kind is left as it is.
+func setKind(field *vcard.Field, kind string) {
+	if field.Params == nil {
+		field.Params = vcard.Params{}
+	}
+	if entryKind(field.Params) == kind {
+		return
+	}
+	types := slices.DeleteFunc(field.Params[vcard.ParamType], func(t string) bool {
+		return strings.EqualFold(t, vcard.TypeWork) || strings.EqualFold(t, vcard.TypeHome)
+	})
+	if kind == vcard.TypeWork || kind == vcard.TypeHome {
+		types = append(types, kind)
+	}
+	if len(types) == 0 {
+		delete(field.Params, vcard.ParamType)
+	} else {
+		field.Params[vcard.ParamType] = types
+	}
+}
+
+// transferPhoto links the photo of a web address, or embeds the photo of a local image file as data URI.
+// The embedded photo of the card is kept when the form shows its placeholder.
+func transferPhoto(card vcard.Card, photo string, fileSystem afero.Fs) error {
+	switch {
+	case photo == embeddedPhoto, photo == card.Value(vcard.FieldPhoto):
+		return nil
+	case photo == "":
+		delete(card, vcard.FieldPhoto)
+	case isWebAddress(photo):
+		card.Set(vcard.FieldPhoto, &vcard.Field{Value: photo, Params: vcard.Params{}})
+	default:
+		data, mediaType, err := readPhoto(fileSystem, photo)
+		if err != nil {
+			return err
+		}
+		card.Set(vcard.FieldPhoto, &vcard.Field{Value: "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data), Params: vcard.Params{}})
+	}
+	return nil
+}
+
+// readPhoto reads a local PNG, JPEG or GIF image.
+func readPhoto(fileSystem afero.Fs, path string) ([]byte, string, error) {
+	data, err := afero.ReadFile(fileSystem, path)
+	if err != nil {
+		return nil, "", err
+	}
+	mediaType := http.DetectContentType(data)
+	if mediaType != "image/png" && mediaType != "image/jpeg" && mediaType != "image/gif" {
+		return nil, "", errors.New("The photo must be a PNG, JPEG or GIF image")
+	}
+	return data, mediaType, nil
+}
+
+func validateBirthday(birthday string) error {
+	birthday = strings.TrimSpace(birthday)
+	if birthday == "" {
+		return nil
+	}
+	if !birthdayFormat.MatchString(birthday) {
+		return errors.New("Use a date like 1990-05-17, or --05-17 without year")
+	}
+
+	//the year 2000 is a leap year and accepts the 29th of February without year
+	date := strings.ReplaceAll(strings.Replace(birthday, "--", "2000", 1), "-", "")
+	if _, err := time.Parse("20060102", date); err != nil {
+		return errors.New("The birthday is not a valid date")
+	}
+	return nil
+}
+
+func validatePhoto(fileSystem afero.Fs, photo string) error {
+	photo = strings.TrimSpace(photo)
+	if photo == "" || photo == embeddedPhoto || isWebAddress(photo) {
+		return nil
+	}
+	_, _, err := readPhoto(fileSystem, photo)
+	return err
+}
+
+// prepareForm builds the form of the card, the inputs check their values for the region while they are given.
+func prepareForm(formData *qrCardFormData, region string, fileSystem afero.Fs) *huh.Form {
+	validatePhoneNumber := func(phoneNumber string) error {
+		return qrcard.ValidatePhoneNumber(phoneNumber, region)
+	}
+	validateLocalPhoto := func(photo string) error {
+		return validatePhoto(fileSystem, photo)
+	}
+
+	groups := []*huh.Group{
 		huh.NewGroup(
 			huh.NewInput().Title("Given (first) name").Value(&formData.name.GivenName),
 			huh.NewInput().Title("Additional (middle) name").Value(&formData.name.AdditionalName),
 			huh.NewInput().Title("Family name").Value(&formData.name.FamilyName),
 			huh.NewInput().Title("Honorific prefix (e.g. Capt.)").Value(&formData.name.HonorificPrefix),
 			huh.NewInput().Title("Honorific suffix (e.g. Sr.)").Value(&formData.name.HonorificSuffix),
+			huh.NewInput().Title("Formatted name, as phones show it").
+				Description("Leave empty to take it from the name.").
+				PlaceholderFunc(func() string {
+					return qrcard.JoinName(formData.name, formData.familyFirst)
+				}, formData.name).
+				Value(&formData.formattedName),
 		),
+		huh.NewGroup(
+			huh.NewInput().Title("Nickname").Value(&formData.nickname),
+		).WithHideFunc(func() bool {
+			//NICKNAME is only known since vCard 3.0
+			return formData.version == config.VCardVersion21
+		}),
 		huh.NewGroup(
 			huh.NewSelect[vcard.Sex]().Title("Gender").Options(
 				huh.NewOption("Male", vcard.SexMale).Selected(vcard.SexMale == formData.gender),
 				huh.NewOption("Female", vcard.SexFemale).Selected(vcard.SexFemale == formData.gender),
 				huh.NewOption("Other", vcard.SexOther).Selected(vcard.SexOther == formData.gender),
-				huh.NewOption("Unspecified", vcard.SexUnspecified).Selected(formData.gender != vcard.SexMale && formData.gender != vcard.SexFemale && formData.gender != vcard.SexUnspecified),
+				huh.NewOption("Unspecified", vcard.SexUnspecified).Selected(vcard.SexUnspecified == formData.gender),
+				huh.NewOption("Not given", vcard.Sex("")).Selected(formData.gender == ""),
 			).Value(&formData.gender),
-		),
+		).WithHideFunc(func() bool {
+			//GENDER is only known since vCard 4.0
+			return formData.version != config.VCardVersion40
+		}),
 		huh.NewGroup(
 			huh.NewInput().Title("Job title").Value(&formData.title),
+			huh.NewInput().Title("Role (e.g. Project leader)").Value(&formData.role),
 			huh.NewInput().Title("Organization or company").Value(&formData.organization),
 			huh.NewInput().Title("Department").Value(&formData.department),
 		),
+	}
 
-		huh.NewGroup(
-			huh.NewInput().Title("Mail").Value(&formData.email),
-			huh.NewInput().Title("Web address").Value(&formData.url),
-			huh.NewInput().Title("Cell phone").Value(&formData.cellPhone),
-			huh.NewInput().Title("Work phone").Value(&formData.workPhone),
-			huh.NewInput().Title("Private phone").Value(&formData.homePhone),
-		),
+	contactFields := typedEntryFields("Mail", formData.emails, qrcard.ValidateEmail)
+	contactFields = append(contactFields, typedEntryFields("Web address", formData.urls, qrcard.ValidateURL)...)
+	contactFields = append(contactFields,
+		huh.NewInput().Title("Cell phone").Value(&formData.cellPhone).Validate(validatePhoneNumber),
+		huh.NewInput().Title("Work phone").Value(&formData.workPhone).Validate(validatePhoneNumber),
+		huh.NewInput().Title("Private phone").Value(&formData.homePhone).Validate(validatePhoneNumber),
+	)
+	groups = append(groups, huh.NewGroup(contactFields...))
 
+	for _, entry := range formData.addresses {
+		//the postal code is checked again when the country changes, a postal code that can not be checked is accepted
+		validatePostalCode := func(postalCode string) error {
+			return checkedPostalCode(postalCode, entry.address.Country, region)
+		}
+		validateCountry := func(country string) error {
+			return checkedPostalCode(entry.address.PostalCode, country, region)
+		}
+		groups = append(groups, huh.NewGroup(
+			kindSelect("Kind of address", &entry.kind).Description("Leave all parts of the address empty to remove it."),
+			huh.NewInput().Title("Post office box").Value(&entry.address.PostOfficeBox),
+			huh.NewInput().Title("Street address").Value(&entry.address.StreetAddress),
+			huh.NewInput().Title("Extended street address (e.g. building, floor)").Value(&entry.address.ExtendedAddress),
+			huh.NewInput().Title("City").Value(&entry.address.Locality),
+			huh.NewInput().Title("Postal code").Value(&entry.address.PostalCode).Validate(validatePostalCode),
+			huh.NewInput().Title("Country").Value(&entry.address.Country).Validate(validateCountry),
+		))
+	}
+
+	groups = append(groups,
 		huh.NewGroup(
-			huh.NewInput().Title("Post office box").Value(&formData.address.PostOfficeBox),
-			huh.NewInput().Title("Street address").Value(&formData.address.StreetAddress),
-			huh.NewInput().Title("Extended street address (e.g. building, floor)").Value(&formData.address.ExtendedAddress),
-			huh.NewInput().Title("City").Value(&formData.address.Locality),
-			huh.NewInput().Title("Postal code").Value(&formData.address.PostalCode),
-			huh.NewInput().Title("Country").Value(&formData.address.Country),
+			huh.NewInput().Title("Birthday (e.g. 1990-05-17)").Value(&formData.birthday).Validate(validateBirthday),
+			huh.NewText().Title("Note").Value(&formData.note),
+			huh.NewInput().Title("Photo (path of a local image or web address)").
+				Description("An embedded photo makes the QR code much larger than a linked one.").
+				Value(&formData.photo).Validate(validateLocalPhoto),
 		),
+	)
+
+	return huh.NewForm(groups...).WithTheme(huh.ThemeBase16())
+}
+
+// checkedPostalCode returns the problem of a postal code that has been checked and is not valid.
+func checkedPostalCode(postalCode, country, region string) error {
+	if err := qrcard.ValidatePostalCode(postalCode, country, region); err != nil && !qrcard.IsUnchecked(err) {
+		return err
+	}
+	return nil
+}
+
+// typedEntryFields returns an input and a kind for each entry, an entry is removed by leaving its input empty.
+func typedEntryFields(title string, entries []*typedEntry, validate func(string) error) []huh.Field {
+	fields := []huh.Field{}
+	for _, entry := range entries {
+		fields = append(fields,
+			huh.NewInput().Title(title).Description("Leave empty to remove.").Value(&entry.value).Validate(validate),
+			kindSelect("Kind of "+strings.ToLower(title), &entry.kind))
+	}
+	return fields
+}
+
+func kindSelect(title string, kind *string) *huh.Select[string] {
+	return huh.NewSelect[string]().Title(title).Inline(true).Options(
+		huh.NewOption("Work", vcard.TypeWork),
+		huh.NewOption("Home", vcard.TypeHome),
+		huh.NewOption("Other", typeOther),
+	).Value(kind)
+}
+
+func prepareConfirmForm(formData *qrCardFormData) *huh.Form {
 
+	confirmForm := huh.NewForm(
 		huh.NewGroup(
-			huh.NewConfirm().
+			huh.NewSelect[string]().
 				Title("Are you ready?").
-				Affirmative("Yes, print the result!").
-				Negative("No, I´m not ready.").
-				Value(&formData.ready),
+				Options(
+					huh.NewOption("Yes, print the result!", nextReady),
+					huh.NewOption("No, I´m not ready.", nextEdit),
+					huh.NewOption("Add another mail", nextAddEmail),
+					huh.NewOption("Add another web address", nextAddURL),
+					huh.NewOption("Add another postal address", nextAddAddress),
+				).
+				Value(&formData.next),
 		),
 	).WithTheme(huh.ThemeBase16())
-	return vCardForm
+	return confirmForm
 }
@@ -1,11 +1,15 @@
 package editorcli
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/emersion/go-vcard"
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 
+	vcardcodec "github.com/ulfschneider/qrvc/internal/adapters/codec/vcard"
+	"github.com/ulfschneider/qrvc/internal/application/config"
 	qrcard "github.com/ulfschneider/qrvc/internal/domain"
 	testutil "github.com/ulfschneider/qrvc/internal/test/util"
 )
@@ -14,11 +18,11 @@ func TestEditor(t *testing.T) {
 
 	card := testutil.CreateCard()
 
-	formData := transferVCardIntoFormData(card)
+	formData := transferVCardIntoFormData(card, false)
 	assert.Equal(t, card.Name(), formData.name)
-	assert.Equal(t, card.Address(), formData.address)
-	assert.Equal(t, "Email address", formData.email)
-	assert.Equal(t, "Web address", formData.url)
+	assert.Equal(t, card.Address(), formData.addresses[0].address)
+	assert.Equal(t, "Email address", formData.emails[0].value)
+	assert.Equal(t, "Web address", formData.urls[0].value)
 	assert.Equal(t, "Cell phone", formData.cellPhone)
 	assert.Equal(t, "Work phone", formData.workPhone)
 	assert.Equal(t, "Home phone", formData.homePhone)
@@ -35,20 +39,26 @@ func TestEditor(t *testing.T) {
 	formData.title = "title"
 	formData.organization = "organization"
 	formData.department = "department"
-	formData.email = "email address"
-	formData.url = "url"
+	formData.emails[0].value = "email address"
+	formData.urls[0].value = "url"
 	formData.cellPhone = "cell phone number"
 	formData.workPhone = "work phone number"
 	formData.homePhone = "home phone number"
-	formData.address.PostOfficeBox = "post office box"
-	formData.address.StreetAddress = "street address"
-	formData.address.ExtendedAddress = "extended address"
-	formData.address.PostalCode = "postal code"
-	formData.address.Locality = "city"
-	formData.address.Country = "country"
+	formData.addresses[0].address.PostOfficeBox = "post office box"
+	formData.addresses[0].address.StreetAddress = "street address"
+	formData.addresses[0].address.ExtendedAddress = "extended address"
+	formData.addresses[0].address.PostalCode = "postal code"
+	formData.addresses[0].address.Locality = "city"
+	formData.addresses[0].address.Country = "country"
+
+	formData.nickname = "nickname"
+	formData.birthday = "1990-05-17"
+	formData.role = "role"
+	formData.note = "note"
+	formData.photo = "https://example.com/photo.jpg"
 
 	//bring the input form data back into the vcard
-	transferFormDataIntoVCard(card, formData)
+	assert.NoError(t, transferFormDataIntoVCard(card, formData, afero.NewMemMapFs()))
 
 	assert.Equal(t, "given", card.Name().GivenName)
 	assert.Equal(t, "additional", card.Name().AdditionalName)
@@ -71,5 +81,177 @@ func TestEditor(t *testing.T) {
 	assert.Equal(t, "city", card.Address().Locality)
 	assert.Equal(t, "postal code", card.Address().PostalCode)
 	assert.Equal(t, "country", card.Address().Country)
+	assert.Equal(t, "nickname", card.Value(vcard.FieldNickname))
+	assert.Equal(t, "1990-05-17", card.Value(vcard.FieldBirthday))
+	assert.Equal(t, "role", card.Value(vcard.FieldRole))
+	assert.Equal(t, "note", card.Value(vcard.FieldNote))
+	assert.Equal(t, "https://example.com/photo.jpg", card.Value(vcard.FieldPhoto))
+	assert.Equal(t, "https://example.com/photo.jpg", transferVCardIntoFormData(card, false).photo)
+
+	//empty fields are removed
+	formData.nickname = ""
+	formData.photo = " "
+	assert.NoError(t, transferFormDataIntoVCard(card, formData, afero.NewMemMapFs()))
+	assert.Nil(t, card.Get(vcard.FieldNickname))
+	assert.Nil(t, card.Get(vcard.FieldPhoto))
+}
+
+func TestEditorFormattedName(t *testing.T) {
+	card := testutil.CreateCard()
+
+	//the formatted name of the card is derived from the name and therefore not shown
+	formData := transferVCardIntoFormData(card, false)
+	assert.Equal(t, "", formData.formattedName)
+
+	//and derived again from the edited name
+	formData.name.HonorificPrefix = ""
+	formData.name.HonorificSuffix = ""
+	formData.name.AdditionalName = ""
+	assert.NoError(t, transferFormDataIntoVCard(card, formData, afero.NewMemMapFs()))
+	assert.Equal(t, "Given name Family name", card.Value(vcard.FieldFormattedName))
+
+	//in the order of the family name first, the formatted name is one of the user
+	formData = transferVCardIntoFormData(card, true)
+	assert.Equal(t, "Given name Family name", formData.formattedName)
+	formData.formattedName = ""
+	assert.NoError(t, transferFormDataIntoVCard(card, formData, afero.NewMemMapFs()))
+	assert.Equal(t, "Family name Given name", card.Value(vcard.FieldFormattedName))
+
+	//a formatted name of the user is kept
+	formData = transferVCardIntoFormData(card, true)
+	formData.formattedName = "The Boss"
+	assert.NoError(t, transferFormDataIntoVCard(card, formData, afero.NewMemMapFs()))
+	assert.Equal(t, "The Boss", card.Value(vcard.FieldFormattedName))
+	assert.Equal(t, "The Boss", transferVCardIntoFormData(card, true).formattedName)
+}
+
+func TestEditorEntries(t *testing.T) {
+	card := testutil.CreateCard()
+	card.Add(vcard.FieldEmail, &vcard.Field{Value: "home@example.com", Params: vcard.Params{vcard.ParamType: {"internet", vcard.TypeHome}}})
+	card.Add(vcard.FieldAddress, &vcard.Field{Value: ";;Main Street 1;Berlin;;10115;Germany", Params: vcard.Params{vcard.ParamType: {vcard.TypeWork}, "LABEL": {"Main Street 1"}}})
+
+	formData := transferVCardIntoFormData(card, false)
+	assert.Len(t, formData.emails, 2)
+	assert.Equal(t, typeOther, formData.emails[0].kind)
+	assert.Equal(t, vcard.TypeHome, formData.emails[1].kind)
+	assert.Len(t, formData.addresses, 2)
+	assert.Equal(t, vcard.TypeWork, formData.addresses[1].kind)
+	assert.Len(t, formData.urls, 1)
+
+	//add entries
+	formData.add(nextAddEmail)
+	formData.add(nextAddURL)
+	formData.add(nextAddAddress)
+	formData.add(nextEdit)
+	assert.Len(t, formData.emails, 3)
+	assert.Len(t, formData.urls, 2)
+	assert.Len(t, formData.addresses, 3)
+
+	formData.emails[0].value = ""
+	formData.emails[1].kind = vcard.TypeWork
+	formData.emails[2].value = "other@example.com"
+	formData.urls[1].value = "https://example.com"
+	formData.urls[1].kind = vcard.TypeHome
+	formData.addresses[2].address.Locality = "Hamburg"
+	assert.NoError(t, transferFormDataIntoVCard(card, formData, afero.NewMemMapFs()))
+
+	//the cleared mail is removed, the other one keeps its parameters
+	emails := card[vcard.FieldEmail]
+	assert.Len(t, emails, 2)
+	assert.Equal(t, "home@example.com", emails[0].Value)
+	assert.Equal(t, []string{"internet", vcard.TypeWork}, emails[0].Params[vcard.ParamType])
+	assert.Equal(t, "other@example.com", emails[1].Value)
+	assert.Empty(t, emails[1].Params[vcard.ParamType])
+
+	assert.Equal(t, []string{"Web address", "https://example.com"}, card.Values(vcard.FieldURL))
+	assert.Equal(t, []string{vcard.TypeHome}, card[vcard.FieldURL][1].Params[vcard.ParamType])
+
+	addresses := card.Addresses()
+	assert.Len(t, addresses, 3)
+	assert.Equal(t, "Berlin", addresses[1].Locality)
+	assert.Equal(t, "Main Street 1", addresses[1].Params.Get("LABEL"))
+	assert.Equal(t, "Hamburg", addresses[2].Locality)
+
+	//empty addresses are removed
+	formData = transferVCardIntoFormData(card, false)
+	*formData.addresses[0].address = vcard.Address{Field: formData.addresses[0].address.Field}
+	assert.NoError(t, transferFormDataIntoVCard(card, formData, afero.NewMemMapFs()))
+	assert.Len(t, card.Addresses(), 2)
+}
+
+func TestEditorPhoto(t *testing.T) {
+	card := testutil.CreateCard()
+	fileSystem := afero.NewMemMapFs()
+
+	//a GIF header is enough to be detected as image
+	assert.NoError(t, afero.WriteFile(fileSystem, "photo.gif", []byte("GIF89a"), 0o644))
+
+	formData := transferVCardIntoFormData(card, false)
+	formData.photo = "photo.gif"
+	assert.NoError(t, transferFormDataIntoVCard(card, formData, fileSystem))
+	assert.Equal(t, "data:image/gif;base64,R0lGODlh", card.Value(vcard.FieldPhoto))
+
+	//the embedded photo is shown by a placeholder and kept
+	formData = transferVCardIntoFormData(card, false)
+	assert.Equal(t, embeddedPhoto, formData.photo)
+	assert.NoError(t, transferFormDataIntoVCard(card, formData, fileSystem))
+	assert.Equal(t, "data:image/gif;base64,R0lGODlh", card.Value(vcard.FieldPhoto))
+
+	assert.NoError(t, afero.WriteFile(fileSystem, "photo.txt", []byte("no image"), 0o644))
+	assert.Error(t, validatePhoto(fileSystem, "photo.txt"))
+	assert.Error(t, validatePhoto(fileSystem, "missing.png"))
+	assert.NoError(t, validatePhoto(fileSystem, "https://example.com/photo.jpg"))
+	assert.NoError(t, validatePhoto(fileSystem, ""))
+}
+
+func TestEditorBirthday(t *testing.T) {
+	for _, birthday := range []string{"", "1990-05-17", "19900517", "--05-17", "--0229"} {
+		assert.NoError(t, validateBirthday(birthday), birthday)
+	}
+	for _, birthday := range []string{"17.05.1990", "1990-13-01", "1990-02-30", "90-05-17", "1990-05-32"} {
+		assert.Error(t, validateBirthday(birthday), birthday)
+	}
+}
+
+func TestEditorGolden(t *testing.T) {
+	codec := vcardcodec.NewCodec(config.Settings{})
+
+	//what has not been edited is written back as it has been read
+	for name, vcf := range testutil.GoldenVCards() {
+		t.Run(name, func(t *testing.T) {
+			card, err := codec.Decode(vcf)
+			assert.NoError(t, err)
+
+			assert.NoError(t, transferFormDataIntoVCard(card, transferVCardIntoFormData(card, false), afero.NewMemMapFs()))
+			encoded, err := codec.Encode(card)
+			assert.NoError(t, err)
+			assert.Equal(t, string(vcf), string(encoded))
+		})
+	}
+
+	//an edited field keeps its group and parameters
+	vcf := testutil.GoldenVCards()["ios.vcf"]
+	card, err := codec.Decode(vcf)
+	assert.NoError(t, err)
+	formData := transferVCardIntoFormData(card, false)
+	formData.emails[0].value = "johnny.appleseed@example.com"
+	formData.cellPhone = "+49 171 7654321"
+	assert.NoError(t, transferFormDataIntoVCard(card, formData, afero.NewMemMapFs()))
+	encoded, err := codec.Encode(card)
+	assert.NoError(t, err)
+	expected := strings.NewReplacer(
+		"item1.EMAIL;type=INTERNET;type=pref:johnny@example.com", "item1.EMAIL;TYPE=INTERNET;TYPE=pref:johnny.appleseed@example.com",
+		"TEL;type=CELL;type=VOICE;type=pref:+49 171 1234567", "TEL;TYPE=CELL;TYPE=VOICE;TYPE=pref:+49 171 7654321",
+	).Replace(string(vcf))
+	assert.Equal(t, expected, string(encoded))
 
+	//a card without name and address does not get them
+	card = vcard.Card{}
+	card.SetValue(vcard.FieldVersion, "4.0")
+	card.SetValue(vcard.FieldFormattedName, "Johnny")
+	assert.NoError(t, transferFormDataIntoVCard(card, transferVCardIntoFormData(card, false), afero.NewMemMapFs()))
+	assert.Nil(t, card.Name())
+	assert.Nil(t, card.Address())
+	assert.Nil(t, card.Get(vcard.FieldGender))
+	assert.Nil(t, card.Get(vcard.FieldOrganization))
 }
@@ -2,6 +2,9 @@ package notifiercli
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"sync"
 
 	"github.com/fatih/color"
 )
@@ -9,6 +12,12 @@ import (
 var isSilent bool
 var section bool
 
+// output receives all messages, it is switched to stderr when stdout carries the result
+var output io.Writer = os.Stdout
+
+// mutex keeps the output of notifiers that are used in parallel from interleaving
+var mutex sync.Mutex
+
 type UserNotifier struct {
 }
 
@@ -38,23 +47,34 @@ func (c *UserNotifier) format(values ...any) []any {
 }
 
 func (c *UserNotifier) NotifyLoud(values ...any) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
 	section = false
-	fmt.Println(values...)
+	fmt.Fprintln(output, values...)
 }
 
 func (c *UserNotifier) NotifyfLoud(format string, values ...any) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
 	section = false
-	fmt.Printf(format+"\n", c.format(values...)...)
+	fmt.Fprintf(output, format+"\n", c.format(values...)...)
 }
 
 func (c *UserNotifier) Notifyf(format string, values ...any) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
 	if isSilent == false {
 		section = false
-		fmt.Printf(format+"\n", c.format(values...)...)
+		fmt.Fprintf(output, format+"\n", c.format(values...)...)
 	}
 }
 
 func (c *UserNotifier) Notify(values ...any) {
+	mutex.Lock()
+	defer mutex.Unlock()
 
 	var isError bool
 	section = false
@@ -67,28 +87,47 @@ func (c *UserNotifier) Notify(values ...any) {
 	}
 
 	if isSilent == false || isError {
-		fmt.Println(values...)
+		fmt.Fprintln(output, values...)
 	}
 }
 
 func (c *UserNotifier) Section() {
+	mutex.Lock()
+	defer mutex.Unlock()
+
 	if section == false && isSilent == false {
 		section = true
-		fmt.Println()
+		fmt.Fprintln(output)
 	}
 }
 
 func (c *UserNotifier) SectionLoud() {
+	mutex.Lock()
+	defer mutex.Unlock()
+
 	if section == false {
 		section = true
-		fmt.Println()
+		fmt.Fprintln(output)
 	}
 }
 
 func (c *UserNotifier) SetSilent(silent bool) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
 	isSilent = silent
 }
 
+func (c *UserNotifier) SetOutput(w io.Writer) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	output = w
+}
+
 func (c *UserNotifier) Silent() bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+
 	return isSilent
 }
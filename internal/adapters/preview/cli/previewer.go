@@ -0,0 +1,164 @@
+package previewcli
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/emersion/go-vcard"
+
+	notifiercli "github.com/ulfschneider/qrvc/internal/adapters/notifier/cli"
+	"github.com/ulfschneider/qrvc/internal/application/config"
+	"github.com/ulfschneider/qrvc/internal/application/ports"
+)
+
+type QRCodePreviewer struct {
+	qrCodec      ports.QRCodec
+	settings     config.Settings
+	userNotifier notifiercli.UserNotifier
+}
+
+func NewQRCodePreviewer(qrCodec ports.QRCodec, settings config.Settings) QRCodePreviewer {
+	return QRCodePreviewer{
+		qrCodec:      qrCodec,
+		settings:     settings,
+		userNotifier: notifiercli.NewUserNotifier(),
+	}
+}
+
+// minModuleSize is the smallest width of a module in millimeters that phone cameras read reliably at a usual distance.
+const minModuleSize = 0.4
+
+// minModuleDots is the smallest number of printer dots per module, a single dot is blurred too easily.
+const minModuleDots = 2
+
+// quietZoneModules is the width of the quiet zone around the symbol, which the QR code standard asks for.
+const quietZoneModules = 4
+
+const mmPerInch = 25.4
+
+// Preview shows the QR code of the card in the terminal, unless the notifier is silent.
+func (p *QRCodePreviewer) Preview(card vcard.Card) error {
+	if p.userNotifier.Silent() {
+		return nil
+	}
+
+	qr, _, err := p.render(card)
+	if err != nil {
+		return err
+	}
+
+	p.userNotifier.Section()
+	p.userNotifier.Notify(qr)
+	return nil
+}
+
+// Print shows the QR code of the card in the terminal, even when the notifier is silent, and returns the description of the shown QR code.
+func (p *QRCodePreviewer) Print(card vcard.Card) (ports.QRCodeInfo, error) {
+	qr, info, err := p.render(card)
+	if err != nil {
+		return ports.QRCodeInfo{}, err
+	}
+
+	p.userNotifier.SectionLoud()
+	p.userNotifier.NotifyLoud(qr)
+	return info, nil
+}
+
+// Info shows the version, modules, recovery level, mask pattern and payload of a QR code that has been written or printed,
+// together with the smallest size to print it at the dpi of the settings. The information is shown even when the notifier is silent.
+func (p *QRCodePreviewer) Info(info ports.QRCodeInfo) error {
+	info = printSize(info, p.settings.DPI)
+
+	if p.settings.Info == config.InfoJSON {
+		data, err := json.Marshal(info)
+		if err != nil {
+			return err
+		}
+		p.userNotifier.NotifyLoud(string(data))
+		return nil
+	}
+
+	p.userNotifier.SectionLoud()
+	p.userNotifier.NotifyfLoud("QR code version %s with %s modules, recovery level %s and mask pattern %s", info.Version, fmt.Sprintf("%dx%d", info.Modules, info.Modules), info.RecoveryLevel, info.Mask)
+	p.userNotifier.NotifyfLoud("The payload is a %s of %s bytes", info.Payload, info.PayloadBytes)
+	p.userNotifier.NotifyfLoud("At %s dpi print each module with at least %s dots (%s mm), which makes the QR code at least %s mm wide including the quiet zone", info.DPI, info.ModuleDots, info.ModuleSize, info.PrintSize)
+	return nil
+}
+
+// printSize adds the smallest size to print the QR code at the resolution in dots per inch to the description of the QR code.
+func printSize(info ports.QRCodeInfo, dpi int) ports.QRCodeInfo {
+	//a module is a whole number of printer dots
+	moduleDots := max(minModuleDots, int(math.Ceil(minModuleSize*float64(dpi)/mmPerInch)))
+	moduleSize := float64(moduleDots) * mmPerInch / float64(dpi)
+
+	info.DPI = dpi
+	info.ModuleDots = moduleDots
+	info.ModuleSize = math.Round(moduleSize*1000) / 1000
+	//the quiet zone is needed in print, even when the image has no border
+	info.PrintSize = math.Round(float64(info.Modules+2*quietZoneModules)*moduleSize*10) / 10
+	return info
+}
+
+func (p *QRCodePreviewer) render(card vcard.Card) (string, ports.QRCodeInfo, error) {
+	//a terminal QR code always needs the quiet zone to be scannable
+	qrSettings := p.settings.QRSettings
+	qrSettings.Border = true
+	//the terminal draws with its own colors, which leaves nothing to verify
+	qrSettings.Verify = false
+
+	bitmap, info, err := p.qrCodec.Bitmap(card, qrSettings)
+	if err != nil {
+		return "", ports.QRCodeInfo{}, err
+	}
+
+	if p.settings.TerminalStyle == config.TerminalASCII {
+		return renderASCII(bitmap), info, nil
+	}
+	return renderUnicode(bitmap), info, nil
+}
+
+// renderUnicode draws two module rows per line with half-block characters.
+// Light modules are drawn with the text color, which suits terminals with light text on a dark background.
+func renderUnicode(bitmap [][]bool) string {
+	var qr strings.Builder
+	for y := 0; y < len(bitmap); y += 2 {
+		for x := range bitmap[y] {
+			top := bitmap[y][x]
+			bottom := y+1 < len(bitmap) && bitmap[y+1][x]
+			switch {
+			case !top && !bottom:
+				qr.WriteString("█")
+			case !top && bottom:
+				qr.WriteString("▀")
+			case top && !bottom:
+				qr.WriteString("▄")
+			default:
+				qr.WriteString(" ")
+			}
+		}
+		if y+2 < len(bitmap) {
+			qr.WriteString("\n")
+		}
+	}
+	return qr.String()
+}
+
+// renderASCII draws each dark module as two hash characters, which suits plain text output and light backgrounds.
+func renderASCII(bitmap [][]bool) string {
+	var qr strings.Builder
+	for y, row := range bitmap {
+		for _, dark := range row {
+			if dark {
+				qr.WriteString("##")
+			} else {
+				qr.WriteString("  ")
+			}
+		}
+		if y+1 < len(bitmap) {
+			qr.WriteString("\n")
+		}
+	}
+	return qr.String()
+}
@@ -0,0 +1,37 @@
+package previewcli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ulfschneider/qrvc/internal/application/ports"
+)
+
+var testBitmap = [][]bool{
+	{true, false, true},
+	{true, true, false},
+	{false, true, false},
+}
+
+func TestRenderUnicode(t *testing.T) {
+	assert.Equal(t, " ▀▄\n█▄█", renderUnicode(testBitmap))
+}
+
+func TestRenderASCII(t *testing.T) {
+	assert.Equal(t, "##  ##\n####  \n  ##  ", renderASCII(testBitmap))
+}
+
+func TestPrintSize(t *testing.T) {
+	info := printSize(ports.QRCodeInfo{Version: 5, Modules: 37}, 300)
+
+	//at 300 dpi a module of 0.4 mm needs 5 dots
+	assert.Equal(t, 300, info.DPI)
+	assert.Equal(t, 5, info.ModuleDots)
+	assert.Equal(t, 0.423, info.ModuleSize)
+	assert.Equal(t, 19.1, info.PrintSize)
+
+	//a low resolution still needs two dots per module
+	info = printSize(ports.QRCodeInfo{Version: 5, Modules: 37}, 72)
+	assert.Equal(t, 2, info.ModuleDots)
+}
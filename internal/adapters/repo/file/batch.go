@@ -0,0 +1,179 @@
+package repofile
+
+import (
+	"fmt"
+	"iter"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/emersion/go-vcard"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+
+	configcli "github.com/ulfschneider/qrvc/internal/adapters/config/cli"
+	"github.com/ulfschneider/qrvc/internal/application/ports"
+	qrcard "github.com/ulfschneider/qrvc/internal/domain"
+)
+
+// ReadVCardBatch resolves the batch input into vCard, CSV and QR code image files and yields the cards of those files in a stable order.
+// Every card gets a unique output name, derived from the name template, which keeps the names deterministic when the cards are written in parallel.
+func (fr *Repository) ReadVCardBatch() (iter.Seq[ports.BatchCard], error) {
+	paths, err := fr.batchInputPaths()
+	if err != nil {
+		return nil, err
+	}
+
+	mapping, err := fr.csvMapping()
+	if err != nil {
+		return nil, err
+	}
+
+	fr.userNotifier.Section()
+	fr.userNotifier.Notifyf("Reading %s vCard files from %s", len(paths), fr.fileSettings.ReadVCardPath)
+	fr.userNotifier.Section()
+
+	return func(yield func(ports.BatchCard) bool) {
+		names := map[string]bool{}
+
+		for _, path := range paths {
+			data, err := fr.readFile(path)
+			if err != nil {
+				if !yield(ports.BatchCard{Source: path, Err: err}) {
+					return
+				}
+				continue
+			}
+
+			var cards []vcard.Card
+			if isCSV(path) {
+				cards, err = fr.decodeCSV(data, mapping)
+			} else if isImage(data) {
+				var card vcard.Card
+				if card, err = fr.decodeCard(data); err == nil {
+					cards = []vcard.Card{card}
+				}
+			} else {
+				cards, err = fr.cardCodec.DecodeAll(data)
+			}
+			if err != nil {
+				if !yield(ports.BatchCard{Source: path, Err: err}) {
+					return
+				}
+				continue
+			}
+
+			for i, card := range cards {
+				source := path
+				if len(cards) > 1 {
+					source = fmt.Sprintf("%s#%d", path, i+1)
+				}
+				card = fr.convert(card, source)
+				fr.normalize(card, source)
+				fr.completeName(card, source)
+				name := uniqueName(batchName(card, fr.fileSettings.NameTemplate), names)
+				if !yield(ports.BatchCard{Source: source, Name: name, Card: card}) {
+					return
+				}
+			}
+		}
+	}, nil
+}
+
+func isCSV(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".csv")
+}
+
+// batchInputPaths returns the vCard and CSV files of a glob pattern, of a folder, or the given file itself.
+func (fr *Repository) batchInputPaths() ([]string, error) {
+	input := fr.fileSettings.ReadVCardPath
+
+	var paths []string
+	if input == configcli.StandardStream {
+		paths = append(paths, input)
+	} else if strings.ContainsAny(input, "*?[") {
+		matches, err := afero.Glob(fr.fileSystem, input)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			if info, err := fr.fileSystem.Stat(match); err == nil && !info.IsDir() {
+				paths = append(paths, match)
+			}
+		}
+	} else {
+		info, err := fr.fileSystem.Stat(input)
+		if err != nil {
+			return nil, err
+		}
+
+		if info.IsDir() {
+			entries, err := afero.ReadDir(fr.fileSystem, input)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range entries {
+				if !entry.IsDir() && (strings.EqualFold(filepath.Ext(entry.Name()), ".vcf") || isCSV(entry.Name())) {
+					paths = append(paths, filepath.Join(input, entry.Name()))
+				}
+			}
+		} else {
+			paths = append(paths, input)
+		}
+	}
+
+	if len(paths) == 0 {
+		return nil, errors.Errorf("No vCard files found for %s", input)
+	}
+
+	slices.Sort(paths)
+	return paths, nil
+}
+
+func (fr *Repository) WriteBatchVCard(card vcard.Card, name string) error {
+	if err := fr.fileSystem.MkdirAll(fr.fileSettings.WriteDirPath, 0755); err != nil {
+		return err
+	}
+	return fr.writeVCard(card, filepath.Join(fr.fileSettings.WriteDirPath, name+".vcf"))
+}
+
+func (fr *Repository) WriteBatchQRCode(card vcard.Card, name string) (ports.QRCodeInfo, error) {
+	if err := fr.fileSystem.MkdirAll(fr.fileSettings.WriteDirPath, 0755); err != nil {
+		return ports.QRCodeInfo{}, err
+	}
+	return fr.writeQRCode(card, filepath.Join(fr.fileSettings.WriteDirPath, name+"."+fr.fileSettings.QRCodeFormat))
+}
+
+// ReleaseBatchCard lets go of what has been kept of the card since reading it, once the batch is done with the card.
+func (fr *Repository) ReleaseBatchCard(card vcard.Card) {
+	fr.cardCodec.Forget(card)
+}
+
+// batchName builds a file name from the template, characters that are not allowed in file names are replaced.
+func batchName(card vcard.Card, template string) string {
+	name := qrcard.FormatName(card, template)
+
+	name = strings.Map(func(r rune) rune {
+		if r < ' ' || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+
+	name = strings.Trim(name, " .")
+	if name == "" {
+		name = "vcard"
+	}
+	return name
+}
+
+// uniqueName appends a counter to names that have been used before, names are compared case insensitive.
+func uniqueName(name string, names map[string]bool) string {
+	unique := name
+	for i := 2; names[strings.ToLower(unique)]; i++ {
+		unique = name + "-" + strconv.Itoa(i)
+	}
+	names[strings.ToLower(unique)] = true
+	return unique
+}
@@ -0,0 +1,144 @@
+package repofile_test
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"slices"
+	"testing"
+
+	"github.com/emersion/go-vcard"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+
+	configcli "github.com/ulfschneider/qrvc/internal/adapters/config/cli"
+	notifiercli "github.com/ulfschneider/qrvc/internal/adapters/notifier/cli"
+	"github.com/ulfschneider/qrvc/internal/application/ports"
+	"github.com/ulfschneider/qrvc/internal/application/services"
+	testutil "github.com/ulfschneider/qrvc/internal/test/util"
+)
+
+func createBatchFiles(t *testing.T, filesystem afero.Fs) {
+	card := testutil.CreateCard()
+	content := testutil.EncodeCard(card)
+
+	other := testutil.CreateCard()
+	other.SetName(&vcard.Name{GivenName: "Other", FamilyName: "Person"})
+	otherContent := testutil.EncodeCard(other)
+
+	assert.NoError(t, filesystem.MkdirAll("team", 0755))
+	assert.NoError(t, afero.WriteFile(filesystem, "team/a.vcf", content, 0644))
+	assert.NoError(t, afero.WriteFile(filesystem, "team/b.vcf", append(append([]byte{}, otherContent...), content...), 0644))
+	assert.NoError(t, afero.WriteFile(filesystem, "team/c.vcf", []byte("broken"), 0644))
+	assert.NoError(t, afero.WriteFile(filesystem, "team/notes.txt", []byte("no vcard"), 0644))
+}
+
+func readBatch(t *testing.T, filesystem afero.Fs, input string) []ports.BatchCard {
+	settings := testutil.LoadTestSettings()
+	settings.Files.Batch = true
+	settings.Files.ReadVCardPath = input
+	settings.Files.NameTemplate = "{family}-{given}"
+	repo := createTestRepo(filesystem, settings)
+
+	cards, err := repo.ReadVCardBatch()
+	assert.NoError(t, err)
+	return slices.Collect(cards)
+}
+
+func TestReadVCardBatchFolder(t *testing.T) {
+	filesystem := afero.NewMemMapFs()
+	createBatchFiles(t, filesystem)
+
+	cards := readBatch(t, filesystem, "team")
+	assert.Len(t, cards, 4)
+
+	assert.Equal(t, "team/a.vcf", cards[0].Source)
+	assert.Equal(t, "Family name-Given name", cards[0].Name)
+	assert.NoError(t, cards[0].Err)
+
+	assert.Equal(t, "team/b.vcf#1", cards[1].Source)
+	assert.Equal(t, "Person-Other", cards[1].Name)
+
+	//the same name is made unique
+	assert.Equal(t, "team/b.vcf#2", cards[2].Source)
+	assert.Equal(t, "Family name-Given name-2", cards[2].Name)
+
+	//a broken file is reported
+	assert.Equal(t, "team/c.vcf", cards[3].Source)
+	assert.Error(t, cards[3].Err)
+}
+
+func TestReadVCardBatchGlobAndFile(t *testing.T) {
+	filesystem := afero.NewMemMapFs()
+	createBatchFiles(t, filesystem)
+
+	cards := readBatch(t, filesystem, "team/[ab].vcf")
+	assert.Len(t, cards, 3)
+
+	cards = readBatch(t, filesystem, "team/b.vcf")
+	assert.Len(t, cards, 2)
+	assert.Equal(t, "Person-Other", cards[0].Name)
+	assert.Equal(t, "Family name-Given name", cards[1].Name)
+
+	//nothing to read
+	settings := testutil.LoadTestSettings()
+	settings.Files.ReadVCardPath = "team/*.png"
+	repo := createTestRepo(filesystem, settings)
+	_, err := repo.ReadVCardBatch()
+	assert.Error(t, err)
+}
+
+func TestWriteBatch(t *testing.T) {
+	filesystem := afero.NewMemMapFs()
+	settings := testutil.LoadTestSettings()
+	settings.Files.Batch = true
+	settings.Files.WriteDirPath = "out"
+	settings.Files.QRCodeFormat = configcli.FormatSVG
+	repo := createTestRepo(filesystem, settings)
+
+	card := testutil.CreateCard()
+	assert.NoError(t, repo.WriteBatchVCard(card, "contact"))
+	_, err := repo.WriteBatchQRCode(card, "contact")
+	assert.NoError(t, err)
+
+	content, err := afero.ReadFile(filesystem, "out/contact.vcf")
+	assert.NoError(t, err)
+	assert.Equal(t, testutil.ExpectedVCF, testutil.NormalizeNewLines(string(content)))
+
+	exists, err := afero.Exists(filesystem, "out/contact.svg")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+}
+
+// TestBatchServiceParallel runs the worker pool against the file repository, run it with -race to check that the workers share nothing they write.
+func TestBatchServiceParallel(t *testing.T) {
+	filesystem := afero.NewMemMapFs()
+	assert.NoError(t, filesystem.MkdirAll("team", 0755))
+	for i := range 20 {
+		card := testutil.CreateValidCard()
+		card.SetName(&vcard.Name{GivenName: fmt.Sprintf("Given %d", i), FamilyName: "Family"})
+		card.SetValue(vcard.FieldFormattedName, fmt.Sprintf("Given %d Family", i))
+		assert.NoError(t, afero.WriteFile(filesystem, fmt.Sprintf("team/%d.vcf", i), testutil.EncodeCard(card), 0644))
+	}
+
+	settings := testutil.LoadTestSettings()
+	settings.Files.Batch = true
+	settings.Files.ReadVCardPath = "team"
+	settings.Files.WriteDirPath = "out"
+	settings.Files.NameTemplate = "{given}"
+	settings.App.Jobs = 4
+	settings.App.QRSettings.Logo = image.NewRGBA(image.Rect(0, 0, 10, 10))
+	repo := createTestRepo(filesystem, settings)
+
+	userNotifier := notifiercli.NewUserNotifier()
+	batchService := services.NewBatchService(settings.App, &repo, &userNotifier)
+	assert.NoError(t, batchService.TransformCards(context.Background()))
+
+	for i := range 20 {
+		for _, ext := range []string{"vcf", "png"} {
+			exists, err := afero.Exists(filesystem, fmt.Sprintf("out/Given %d.%s", i, ext))
+			assert.NoError(t, err)
+			assert.True(t, exists)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package repofile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/emersion/go-vcard"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+
+	qrcard "github.com/ulfschneider/qrvc/internal/domain"
+)
+
+// csvTarget is the vCard field a CSV column is mapped to, like TEL;TYPE=cell or N.family.
+type csvTarget struct {
+	field     string
+	fieldType string
+	component string
+}
+
+// csvFields are the vCard fields that are recognized as column names without a mapping.
+var csvFields = []string{
+	vcard.FieldFormattedName, vcard.FieldName, vcard.FieldNickname, vcard.FieldBirthday, vcard.FieldAddress,
+	vcard.FieldTelephone, vcard.FieldEmail, vcard.FieldTitle, vcard.FieldRole, vcard.FieldOrganization,
+	vcard.FieldNote, vcard.FieldURL,
+}
+
+var nameComponents = []string{"family", "given", "additional", "prefix", "suffix"}
+var addressComponents = []string{"pobox", "extended", "street", "locality", "region", "code", "country"}
+var organizationComponents = []string{"name", "unit"}
+
+// parseCSVTarget reads a target in the form FIELD, FIELD;TYPE=type or FIELD.component.
+func parseCSVTarget(target string) (csvTarget, error) {
+	target = strings.TrimSpace(target)
+
+	field, fieldType, hasType := strings.Cut(target, ";")
+	if hasType {
+		key, value, _ := strings.Cut(fieldType, "=")
+		if !strings.EqualFold(strings.TrimSpace(key), "TYPE") || strings.TrimSpace(value) == "" {
+			return csvTarget{}, errors.Errorf("Invalid parameter in CSV mapping target %s, only TYPE=type is supported", target)
+		}
+		fieldType = strings.ToLower(strings.TrimSpace(value))
+	}
+
+	field, component, hasComponent := strings.Cut(field, ".")
+	field = strings.ToUpper(strings.TrimSpace(field))
+	component = strings.ToLower(strings.TrimSpace(component))
+
+	if field == "" {
+		return csvTarget{}, errors.Errorf("Missing vCard field in CSV mapping target %s", target)
+	}
+
+	if hasComponent {
+		var components []string
+		switch field {
+		case vcard.FieldName:
+			components = nameComponents
+		case vcard.FieldAddress:
+			components = addressComponents
+		case vcard.FieldOrganization:
+			components = organizationComponents
+		}
+		if !slices.Contains(components, component) {
+			return csvTarget{}, errors.Errorf("Unknown component %s in CSV mapping target %s", component, target)
+		}
+	}
+
+	return csvTarget{field: field, fieldType: fieldType, component: component}, nil
+}
+
+// csvMapping reads the column mapping from the mapping file and the mapping flags, the flags win over the file.
+// Each mapping has the form Column=TARGET.
+func (fr *Repository) csvMapping() (map[string]csvTarget, error) {
+	mappings := []string{}
+
+	if fr.fileSettings.CSVMappingPath != "" {
+		data, err := afero.ReadFile(fr.fileSystem, fr.fileSettings.CSVMappingPath)
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				mappings = append(mappings, line)
+			}
+		}
+	}
+	mappings = append(mappings, fr.fileSettings.CSVMappings...)
+
+	mapping := map[string]csvTarget{}
+	for _, m := range mappings {
+		column, target, found := strings.Cut(m, "=")
+		if !found {
+			return nil, errors.Errorf("Invalid CSV mapping %s, use the form Column=FIELD", m)
+		}
+		t, err := parseCSVTarget(target)
+		if err != nil {
+			return nil, err
+		}
+		mapping[strings.TrimSpace(column)] = t
+	}
+	return mapping, nil
+}
+
+// decodeCSV turns every row of the CSV data into a card. The first row names the columns.
+// Columns without a mapping are used when their name is a valid target, otherwise they are ignored.
+func (fr *Repository) decodeCSV(data []byte, mapping map[string]csvTarget) ([]vcard.Card, error) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	//spreadsheets in many countries export with semicolons
+	header, _, _ := bytes.Cut(data, []byte("\n"))
+	if bytes.Count(header, []byte(";")) > bytes.Count(header, []byte(",")) {
+		reader.Comma = ';'
+	}
+
+	columns, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("The CSV file is empty")
+	} else if err != nil {
+		return nil, err
+	}
+
+	targets := make([]*csvTarget, len(columns))
+	for i, column := range columns {
+		if t, ok := mapping[strings.TrimSpace(column)]; ok {
+			targets[i] = &t
+		} else if t, err := parseCSVTarget(column); err == nil && slices.Contains(csvFields, t.field) {
+			targets[i] = &t
+		}
+	}
+	if !slices.ContainsFunc(targets, func(t *csvTarget) bool { return t != nil }) {
+		return nil, errors.New("None of the CSV columns is mapped to a vCard field")
+	}
+
+	cards := []vcard.Card{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		card := make(vcard.Card)
+		card.SetValue(vcard.FieldVersion, fr.appSettings.VCardVersion)
+		for i, value := range record {
+			if i < len(targets) && targets[i] != nil {
+				setCSVValue(card, *targets[i], strings.TrimSpace(value))
+			}
+		}
+		cards = append(cards, card)
+	}
+
+	if len(cards) == 0 {
+		return nil, errors.New("The CSV file has no contacts")
+	}
+	return cards, nil
+}
+
+func setCSVValue(card vcard.Card, target csvTarget, value string) {
+	if value == "" {
+		return
+	}
+
+	switch {
+	case target.field == vcard.FieldName && target.component != "":
+		name := card.Name()
+		if name == nil {
+			name = &vcard.Name{}
+		}
+		*nameComponent(name, target.component) = value
+		card.SetName(name)
+	case target.field == vcard.FieldAddress && target.component != "":
+		address := card.Address()
+		if address == nil {
+			address = &vcard.Address{}
+		}
+		*addressComponent(address, target.component) = value
+		card.SetAddress(address)
+	case target.field == vcard.FieldOrganization && target.component != "":
+		organization, unit, _ := strings.Cut(card.Value(vcard.FieldOrganization), ";")
+		if target.component == "name" {
+			organization = value
+		} else {
+			unit = value
+		}
+		card.SetValue(vcard.FieldOrganization, organization+";"+unit)
+	default:
+		qrcard.SetTypedVcardFieldValue(card, target.field, target.fieldType, value)
+	}
+}
+
+func nameComponent(name *vcard.Name, component string) *string {
+	switch component {
+	case "given":
+		return &name.GivenName
+	case "additional":
+		return &name.AdditionalName
+	case "prefix":
+		return &name.HonorificPrefix
+	case "suffix":
+		return &name.HonorificSuffix
+	default:
+		return &name.FamilyName
+	}
+}
+
+func addressComponent(address *vcard.Address, component string) *string {
+	switch component {
+	case "pobox":
+		return &address.PostOfficeBox
+	case "extended":
+		return &address.ExtendedAddress
+	case "locality":
+		return &address.Locality
+	case "region":
+		return &address.Region
+	case "code":
+		return &address.PostalCode
+	case "country":
+		return &address.Country
+	default:
+		return &address.StreetAddress
+	}
+}
@@ -0,0 +1,110 @@
+package repofile_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/emersion/go-vcard"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ulfschneider/qrvc/internal/application/ports"
+	testutil "github.com/ulfschneider/qrvc/internal/test/util"
+)
+
+func readCSVBatch(t *testing.T, filesystem afero.Fs, mappings []string, mappingPath string) ([]ports.BatchCard, error) {
+	settings := testutil.LoadTestSettings()
+	settings.Files.Batch = true
+	settings.Files.ReadVCardPath = "contacts.csv"
+	settings.Files.NameTemplate = "{family}-{given}"
+	settings.Files.CSVMappings = mappings
+	settings.Files.CSVMappingPath = mappingPath
+	repo := createTestRepo(filesystem, settings)
+
+	cards, err := repo.ReadVCardBatch()
+	if err != nil {
+		return nil, err
+	}
+	return slices.Collect(cards), nil
+}
+
+func TestReadCSVWithMapping(t *testing.T) {
+	filesystem := afero.NewMemMapFs()
+	content := "First name,Last name,Mobile,Company,Department,City,EMAIL\n" +
+		"Ada,Lovelace,+44 123,Analytical Engines,Research,London,ada@example.com\n" +
+		"Alan,Turing,,,,,\n"
+	assert.NoError(t, afero.WriteFile(filesystem, "contacts.csv", []byte(content), 0644))
+
+	cards, err := readCSVBatch(t, filesystem, []string{
+		"First name=N.given",
+		"Last name=N.family",
+		"Mobile=TEL;TYPE=cell",
+		"Company=ORG.name",
+		"Department=ORG.unit",
+		"City=ADR.locality",
+	}, "")
+	assert.NoError(t, err)
+	assert.Len(t, cards, 2)
+
+	card := cards[0].Card
+	assert.Equal(t, "contacts.csv#1", cards[0].Source)
+	assert.Equal(t, "Lovelace-Ada", cards[0].Name)
+	assert.Equal(t, "Lovelace", card.Name().FamilyName)
+	assert.Equal(t, "Ada", card.Name().GivenName)
+	assert.Equal(t, "+44 123", card.Get(vcard.FieldTelephone).Value)
+	assert.Equal(t, []string{"cell"}, card.Get(vcard.FieldTelephone).Params.Types())
+	assert.Equal(t, "Analytical Engines;Research", card.Value(vcard.FieldOrganization))
+	assert.Equal(t, "London", card.Address().Locality)
+	//a column that is named like a vCard field is mapped without a mapping
+	assert.Equal(t, "ada@example.com", card.Value(vcard.FieldEmail))
+
+	//empty values are left out
+	card = cards[1].Card
+	assert.Equal(t, "Turing-Alan", cards[1].Name)
+	assert.Nil(t, card.Get(vcard.FieldTelephone))
+	assert.Nil(t, card.Get(vcard.FieldOrganization))
+}
+
+func TestReadCSVWithMappingFileAndSemicolons(t *testing.T) {
+	filesystem := afero.NewMemMapFs()
+	content := "\ufeffName;Phone;Office\nGrace Hopper;555;1st Street\n"
+	assert.NoError(t, afero.WriteFile(filesystem, "contacts.csv", []byte(content), 0644))
+	mapping := "# HR export\nName=FN\nPhone=TEL;TYPE=work\n\nOffice=ADR.street\n"
+	assert.NoError(t, afero.WriteFile(filesystem, "mapping.txt", []byte(mapping), 0644))
+
+	//the flags win over the mapping file
+	cards, err := readCSVBatch(t, filesystem, []string{"Phone=TEL;TYPE=home"}, "mapping.txt")
+	assert.NoError(t, err)
+	assert.Len(t, cards, 1)
+
+	card := cards[0].Card
+	assert.Equal(t, "contacts.csv", cards[0].Source)
+	assert.Equal(t, "Grace Hopper", card.Value(vcard.FieldFormattedName))
+	assert.Equal(t, []string{"home"}, card.Get(vcard.FieldTelephone).Params.Types())
+	assert.Equal(t, "1st Street", card.Address().StreetAddress)
+}
+
+func TestReadCSVErrors(t *testing.T) {
+	filesystem := afero.NewMemMapFs()
+	assert.NoError(t, afero.WriteFile(filesystem, "contacts.csv", []byte("Mobile,Company\n123,ACME\n"), 0644))
+
+	//invalid mappings are rejected up front
+	for _, mapping := range []string{"Mobile", "Mobile=TEL;PREF=1", "Company=ORG.branch", "Mobile="} {
+		_, err := readCSVBatch(t, filesystem, []string{mapping}, "")
+		assert.Error(t, err, mapping)
+	}
+
+	_, err := readCSVBatch(t, filesystem, nil, "missing.txt")
+	assert.Error(t, err)
+
+	//a file without mapped columns is reported as a failed source
+	cards, err := readCSVBatch(t, filesystem, nil, "")
+	assert.NoError(t, err)
+	assert.Len(t, cards, 1)
+	assert.Error(t, cards[0].Err)
+
+	assert.NoError(t, afero.WriteFile(filesystem, "contacts.csv", []byte("Mobile\n"), 0644))
+	cards, err = readCSVBatch(t, filesystem, []string{"Mobile=TEL"}, "")
+	assert.NoError(t, err)
+	assert.Error(t, cards[0].Err)
+}
@@ -0,0 +1,48 @@
+package repofile
+
+import (
+	"image"
+	"slices"
+)
+
+// moduleRun is a horizontal sequence of dark QR code modules in one row of the bitmap.
+type moduleRun struct {
+	x, y, length int
+}
+
+// moduleRuns merges the dark modules of each bitmap row into runs, which keeps vector output small.
+func moduleRuns(bitmap [][]bool) []moduleRun {
+	runs := []moduleRun{}
+	for y, row := range bitmap {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			length := 1
+			for x+length < len(row) && row[x+length] {
+				length++
+			}
+			runs = append(runs, moduleRun{x: x, y: y, length: length})
+			x += length
+		}
+	}
+	return runs
+}
+
+// withoutArea returns a copy of the bitmap where all modules inside the area are light.
+func withoutArea(bitmap [][]bool, area image.Rectangle) [][]bool {
+	if area.Empty() {
+		return bitmap
+	}
+
+	result := make([][]bool, len(bitmap))
+	for y, row := range bitmap {
+		result[y] = slices.Clone(row)
+		for x := range row {
+			if image.Pt(x, y).In(area) {
+				result[y][x] = false
+			}
+		}
+	}
+	return result
+}
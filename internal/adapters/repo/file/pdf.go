@@ -0,0 +1,250 @@
+package repofile
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/emersion/go-vcard"
+
+	qrcodec "github.com/ulfschneider/qrvc/internal/adapters/codec/qr"
+	configcli "github.com/ulfschneider/qrvc/internal/adapters/config/cli"
+	"github.com/ulfschneider/qrvc/internal/application/config"
+	qrcard "github.com/ulfschneider/qrvc/internal/domain"
+)
+
+const pointsPerMillimeter = 72 / 25.4
+
+// helveticaWidths are the glyph widths of the standard Helvetica font for the printable ASCII characters,
+// in thousandths of the font size. They are used to center the caption.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+// encodePDF writes a single page PDF that places the QR code modules as vector rectangles in the center of the page,
+// optionally followed by caption lines.
+func encodePDF(w io.Writer, bitmap [][]bool, caption []string, page configcli.PageSize, settings config.QRCodeSettings) error {
+	pageWidth := page.Width * pointsPerMillimeter
+	pageHeight := page.Height * pointsPerMillimeter
+	shortSide := min(pageWidth, pageHeight)
+
+	margin := shortSide * 0.08
+	fontSize := max(6, min(14, shortSide/40))
+	lineHeight := fontSize * 1.3
+
+	captionHeight := 0.0
+	if len(caption) > 0 {
+		captionHeight = margin/2 + float64(len(caption))*lineHeight
+	}
+
+	qrSide := min(pageWidth-2*margin, pageHeight-2*margin-captionHeight)
+	if qrSide <= 0 {
+		return fmt.Errorf("The page size %gx%g mm is too small for the QR code", page.Width, page.Height)
+	}
+
+	//PDF coordinates start at the bottom left of the page
+	qrX := (pageWidth - qrSide) / 2
+	qrTop := pageHeight - (pageHeight-qrSide-captionHeight)/2
+	qrY := qrTop - qrSide
+	moduleSize := qrSide / float64(len(bitmap))
+
+	logoArea := qrcodec.LogoArea(bitmap, settings)
+	bitmap = withoutArea(bitmap, logoArea)
+
+	var content bytes.Buffer
+	if fill := pdfFill(settings.BackgroundColor); fill != "" {
+		fmt.Fprintf(&content, "%s\n%s %s %s %s re f\n", fill, pdfNumber(qrX), pdfNumber(qrY), pdfNumber(qrSide), pdfNumber(qrSide))
+	}
+
+	foreground := pdfFill(settings.ForegroundColor)
+	if foreground != "" {
+		content.WriteString(foreground + "\n")
+		for _, run := range moduleRuns(bitmap) {
+			fmt.Fprintf(&content, "%s %s %s %s re\n",
+				pdfNumber(qrX+float64(run.x)*moduleSize),
+				pdfNumber(qrTop-float64(run.y+1)*moduleSize),
+				pdfNumber(float64(run.length)*moduleSize),
+				pdfNumber(moduleSize))
+		}
+		content.WriteString("f\n")
+	} else {
+		foreground = "0 0 0 rg"
+	}
+
+	resources := "/Font << /F1 5 0 R >>"
+	var logoObjects []string
+	if !logoArea.Empty() {
+		logoBounds := settings.Logo.Bounds()
+		side := float64(logoArea.Dx()-1) * moduleSize
+		scale := min(side/float64(logoBounds.Dx()), side/float64(logoBounds.Dy()))
+		width := float64(logoBounds.Dx()) * scale
+		height := float64(logoBounds.Dy()) * scale
+		x := qrX + (float64(logoArea.Min.X)+0.5)*moduleSize + (side-width)/2
+		y := qrTop - (float64(logoArea.Min.Y)+0.5)*moduleSize - side + (side-height)/2
+		fmt.Fprintf(&content, "q\n%s 0 0 %s %s %s cm\n/Im1 Do\nQ\n", pdfNumber(width), pdfNumber(height), pdfNumber(x), pdfNumber(y))
+
+		var err error
+		if logoObjects, err = pdfImageObjects(settings.Logo, 7); err != nil {
+			return err
+		}
+		resources += " /XObject << /Im1 6 0 R >>"
+	}
+
+	baseline := qrY - margin/2 - fontSize
+	for _, line := range caption {
+		text := pdfText(line)
+		x := (pageWidth - pdfTextWidth(text, fontSize)) / 2
+		fmt.Fprintf(&content, "BT\n%s\n/F1 %s Tf\n%s %s Td\n(%s) Tj\nET\n", foreground, pdfNumber(fontSize), pdfNumber(x), pdfNumber(baseline), pdfEscape(text))
+		baseline -= lineHeight
+	}
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << %s >> /Contents 4 0 R >>", pdfNumber(pageWidth), pdfNumber(pageHeight), resources),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+	}
+	objects = append(objects, logoObjects...)
+
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = pdf.Len()
+		fmt.Fprintf(&pdf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := pdf.Len()
+	fmt.Fprintf(&pdf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&pdf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&pdf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	_, err := w.Write(pdf.Bytes())
+	return err
+}
+
+// pdfImageObjects returns an image object with the RGB values of the image, followed by the soft mask object with its alpha values.
+// The soft mask object gets the given object number.
+func pdfImageObjects(img image.Image, maskObject int) ([]string, error) {
+	bounds := img.Bounds()
+	rgb := make([]byte, 0, bounds.Dx()*bounds.Dy()*3)
+	alpha := make([]byte, 0, bounds.Dx()*bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			rgb = append(rgb, c.R, c.G, c.B)
+			alpha = append(alpha, c.A)
+		}
+	}
+
+	compressedRGB, err := pdfCompress(rgb)
+	if err != nil {
+		return nil, err
+	}
+	compressedAlpha, err := pdfCompress(alpha)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{
+		fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /SMask %d 0 R /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream",
+			bounds.Dx(), bounds.Dy(), maskObject, len(compressedRGB), compressedRGB),
+		fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream",
+			bounds.Dx(), bounds.Dy(), len(compressedAlpha), compressedAlpha),
+	}, nil
+}
+
+func pdfCompress(data []byte) ([]byte, error) {
+	var compressed bytes.Buffer
+	writer := zlib.NewWriter(&compressed)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return compressed.Bytes(), nil
+}
+
+// pdfCaption builds the caption lines from the formatted name, job title and organization of the card.
+func pdfCaption(card vcard.Card) []string {
+	caption := []string{}
+
+	name := qrcard.FormattedName(card)
+
+	organization := []string{}
+	for _, part := range strings.Split(card.Value(vcard.FieldOrganization), ";") {
+		if part = strings.TrimSpace(part); part != "" {
+			organization = append(organization, part)
+		}
+	}
+
+	for _, line := range []string{name, card.Value(vcard.FieldTitle), strings.Join(organization, ", ")} {
+		if line = strings.TrimSpace(line); line != "" {
+			caption = append(caption, line)
+		}
+	}
+	return caption
+}
+
+// pdfFill returns the operator that sets the fill color, or an empty string when the color is fully transparent.
+func pdfFill(c color.Color) string {
+	if c == nil {
+		return ""
+	}
+
+	nrgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+	if nrgba.A == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%s %s %s rg", pdfNumber(float64(nrgba.R)/0xff), pdfNumber(float64(nrgba.G)/0xff), pdfNumber(float64(nrgba.B)/0xff))
+}
+
+func pdfNumber(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1000)/1000, 'f', -1, 64)
+}
+
+// pdfText maps the text to the WinAnsi encoding of the standard fonts, characters outside of Latin-1 are replaced by a question mark.
+func pdfText(s string) string {
+	var text strings.Builder
+	for _, r := range s {
+		if r > 0xff || (r >= 0x7f && r < 0xa0) || (r < ' ' && r != '\t') {
+			r = '?'
+		}
+		text.WriteByte(byte(r))
+	}
+	return text.String()
+}
+
+func pdfTextWidth(text string, fontSize float64) float64 {
+	width := 0
+	for i := 0; i < len(text); i++ {
+		if c := text[i]; c >= ' ' && c <= '~' {
+			width += helveticaWidths[c-' ']
+		} else {
+			width += 556
+		}
+	}
+	return float64(width) * fontSize / 1000
+}
+
+var pdfEscaper = strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`)
+
+func pdfEscape(text string) string {
+	return pdfEscaper.Replace(text)
+}
@@ -1,7 +1,13 @@
 package repofile
 
 import (
+	"bytes"
+	"image"
+	_ "image/jpeg"
 	"image/png"
+	"io"
+	"net/http"
+	"os"
 	"path/filepath"
 
 	"github.com/emersion/go-vcard"
@@ -12,6 +18,7 @@ import (
 	notifiercli "github.com/ulfschneider/qrvc/internal/adapters/notifier/cli"
 	"github.com/ulfschneider/qrvc/internal/application/config"
 	"github.com/ulfschneider/qrvc/internal/application/ports"
+	qrcard "github.com/ulfschneider/qrvc/internal/domain"
 )
 
 func NewRepo(
@@ -29,6 +36,8 @@ func NewRepo(
 		userNotifier: notifiercli.NewUserNotifier(),
 		fileSettings: fileSettings,
 		appSettings:  appSettings,
+		stdin:        os.Stdin,
+		stdout:       os.Stdout,
 	}
 }
 
@@ -39,6 +48,46 @@ type Repository struct {
 	userNotifier notifiercli.UserNotifier
 	fileSettings configcli.FileSettings
 	appSettings  config.Settings
+	stdin        io.Reader
+	stdout       io.Writer
+}
+
+// SetStandardStreams replaces stdin and stdout, which are used for the path -.
+func (fr *Repository) SetStandardStreams(stdin io.Reader, stdout io.Writer) {
+	fr.stdin = stdin
+	fr.stdout = stdout
+}
+
+// readFile reads the file of the path, or stdin for the path -.
+func (fr *Repository) readFile(path string) ([]byte, error) {
+	if path == configcli.StandardStream {
+		return io.ReadAll(fr.stdin)
+	}
+	return afero.ReadFile(fr.fileSystem, path)
+}
+
+// writeFile writes the data to the file of the path, or to stdout for the path -.
+func (fr *Repository) writeFile(path string, data []byte) error {
+	if path == configcli.StandardStream {
+		_, err := fr.stdout.Write(data)
+		return err
+	}
+
+	file, err := fr.fileSystem.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(data)
+	return err
+}
+
+func displayPath(path string) string {
+	if path == configcli.StandardStream {
+		return "stdout"
+	}
+	return path
 }
 
 func (fr *Repository) ReadOrCreateVCard() (vcard.Card, error) {
@@ -47,33 +96,101 @@ func (fr *Repository) ReadOrCreateVCard() (vcard.Card, error) {
 		//no path to a vcard file, create a new card
 		card := make(vcard.Card)
 		card.SetValue(vcard.FieldVersion, fr.appSettings.VCardVersion)
-		ensureNilSafety(card)
 		return card, nil
 	} else if fr.fileSettings.ReadVCardPath == "" && fr.userNotifier.Silent() == true {
 		//no path to a vcard file, but tool runs in silent mode
 		return nil, errors.New("Missing input file path")
 	} else {
 
-		fr.fitReadVCardPath()
-
 		fr.userNotifier.Section()
-		fr.userNotifier.Notifyf("Reading vCard file %s", fr.fileSettings.ReadVCardPath)
+		if fr.fileSettings.ReadVCardPath == configcli.StandardStream {
+			fr.userNotifier.Notify("Reading vCard from stdin")
+		} else {
+			fr.fitReadVCardPath()
+			fr.userNotifier.Notifyf("Reading vCard file %s", fr.fileSettings.ReadVCardPath)
+		}
 		fr.userNotifier.Section()
 
-		data, err := afero.ReadFile(fr.fileSystem, fr.fileSettings.ReadVCardPath)
+		data, err := fr.readFile(fr.fileSettings.ReadVCardPath)
 		if err != nil {
 			return nil, err
 		}
 
-		if card, err := fr.cardCodec.Decode(data); err != nil {
+		if card, err := fr.decodeCard(data); err != nil {
 			return nil, err
 		} else {
-			ensureNilSafety(card)
+			card = fr.convert(card, fr.fileSettings.ReadVCardPath)
+			fr.normalize(card, fr.fileSettings.ReadVCardPath)
+			fr.completeName(card, fr.fileSettings.ReadVCardPath)
 			return card, nil
 		}
 	}
 }
 
+// decodeCard reads the card from vCard data, or from the QR code of a PNG or JPEG image.
+func (fr *Repository) decodeCard(data []byte) (vcard.Card, error) {
+	if !isImage(data) {
+		return fr.cardCodec.Decode(data)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	card, err := fr.qrCodec.Decode(img)
+	if err != nil {
+		return nil, err
+	}
+	if card.Value(vcard.FieldVersion) == "" {
+		//a MeCard has no version
+		card.SetValue(vcard.FieldVersion, fr.appSettings.VCardVersion)
+	}
+	return card, nil
+}
+
+// isImage tells whether the data is a PNG or JPEG image.
+func isImage(data []byte) bool {
+	contentType := http.DetectContentType(data)
+	return contentType == "image/png" || contentType == "image/jpeg"
+}
+
+// convert brings the card into the configured vCard version when converting, and reports the fields the version does not know.
+func (fr *Repository) convert(card vcard.Card, source string) vcard.Card {
+	if !fr.appSettings.Convert {
+		return card
+	}
+
+	converted, dropped := fr.cardCodec.Convert(card, fr.appSettings.VCardVersion)
+	//only the converted copy is written
+	fr.cardCodec.Forget(card)
+	for _, field := range dropped {
+		fr.userNotifier.Notifyf("The field %s of %s is not known in vCard %s and has been left out", field, source, fr.appSettings.VCardVersion)
+	}
+	return converted
+}
+
+// normalize rewrites the phone numbers of the card into E.164 format when asked for, and reports every changed number.
+func (fr *Repository) normalize(card vcard.Card, source string) {
+	if !fr.appSettings.E164 {
+		return
+	}
+
+	for _, change := range qrcard.NormalizePhoneNumbers(card, fr.appSettings.Region) {
+		fr.userNotifier.Notifyf("The phone number %s of %s has been changed to %s", change.From, source, change.To)
+	}
+}
+
+// completeName sets a missing FN from N, because vCard 3.0 and 4.0 require it, and warns when FN and N disagree.
+func (fr *Repository) completeName(card vcard.Card, source string) {
+	familyFirst := fr.appSettings.NameOrder == config.NameOrderFamilyFirst
+	if card.Value(vcard.FieldVersion) != config.VCardVersion21 {
+		qrcard.CompleteFormattedName(card, familyFirst)
+	}
+	if !qrcard.FormattedNameAgrees(card) {
+		fr.userNotifier.Notifyf("The formatted name %s of %s does not agree with the name %s", card.Value(vcard.FieldFormattedName), source, qrcard.JoinName(card.Name(), familyFirst))
+	}
+}
+
 func (fr *Repository) fitReadVCardPath() {
 	if filepath.Ext(fr.fileSettings.ReadVCardPath) == "" {
 		//try .vcf
@@ -87,55 +204,92 @@ func (fr *Repository) fitReadVCardPath() {
 }
 
 func (fr *Repository) WriteVCard(card vcard.Card) error {
-	file, err := fr.fileSystem.Create(fr.fileSettings.WriteVCardPath)
-	if err != nil {
-		return err
+	if fr.fileSettings.WriteVCardPath == "" {
+		//only the QR code is written to stdout
+		return nil
 	}
-	defer file.Close()
+	return fr.writeVCard(card, fr.fileSettings.WriteVCardPath)
+}
 
+func (fr *Repository) writeVCard(card vcard.Card, path string) error {
 	vCardContent, err := fr.cardCodec.Encode(card)
 	if err != nil {
 		return err
 	}
 
-	if _, err := file.Write(vCardContent); err != nil {
+	if err := fr.writeFile(path, vCardContent); err != nil {
 		return err
 	} else {
-		fr.userNotifier.Notifyf("The vCard has been written to %s", fr.fileSettings.WriteVCardPath)
+		fr.userNotifier.Notifyf("The vCard has been written to %s", displayPath(path))
 	}
 
 	return nil
 }
 
-func (fr *Repository) WriteQRCode(card vcard.Card) error {
-	img, err := fr.qrCodec.Encode(card, fr.appSettings.QRSettings)
-	if err != nil {
-		return err
+// WriteQRCode writes the QR code of the card and returns the description of the written QR code.
+func (fr *Repository) WriteQRCode(card vcard.Card) (ports.QRCodeInfo, error) {
+	if fr.fileSettings.WriteQRCodePath == "" {
+		//only the vCard is written to stdout
+		return ports.QRCodeInfo{}, nil
 	}
+	return fr.writeQRCode(card, fr.fileSettings.WriteQRCodePath)
+}
 
-	file, err := fr.fileSystem.Create(fr.fileSettings.WriteQRCodePath)
+func (fr *Repository) writeQRCode(card vcard.Card, path string) (ports.QRCodeInfo, error) {
+	var qrCodeContent bytes.Buffer
+	info, err := fr.encodeQRCode(&qrCodeContent, card)
 	if err != nil {
-		return err
+		return ports.QRCodeInfo{}, err
 	}
-	defer file.Close()
 
-	if err := png.Encode(file, img); err != nil {
-		return err
+	if err := fr.writeFile(path, qrCodeContent.Bytes()); err != nil {
+		return ports.QRCodeInfo{}, err
 	} else {
-		fr.userNotifier.Notifyf("The QR code has been written to %s", fr.fileSettings.WriteQRCodePath)
+		fr.userNotifier.Notifyf("The QR code has been written to %s", displayPath(path))
 	}
 
-	return nil
+	return info, nil
 
 }
 
-func ensureNilSafety(card vcard.Card) {
-	if card.Name() == nil {
-		name := vcard.Name{}
-		card.SetName(&name)
+func (fr *Repository) encodeQRCode(w io.Writer, card vcard.Card) (ports.QRCodeInfo, error) {
+	switch fr.fileSettings.QRCodeFormat {
+	case configcli.FormatSVG:
+		bitmap, info, err := fr.qrCodec.Bitmap(card, fr.appSettings.QRSettings)
+		if err != nil {
+			return ports.QRCodeInfo{}, err
+		}
+		return info, encodeSVG(w, bitmap, fr.appSettings.QRSettings)
+	case configcli.FormatPDF:
+		bitmap, info, err := fr.qrCodec.Bitmap(card, fr.appSettings.QRSettings)
+		if err != nil {
+			return ports.QRCodeInfo{}, err
+		}
+		caption := []string{}
+		if fr.fileSettings.PDFCaption {
+			caption = pdfCaption(card)
+		}
+		return info, encodePDF(w, bitmap, caption, fr.fileSettings.PDFPage, fr.appSettings.QRSettings)
+	default:
+		img, info, err := fr.qrCodec.Encode(card, fr.appSettings.QRSettings)
+		if err != nil {
+			return ports.QRCodeInfo{}, err
+		}
+		return info, png.Encode(w, img)
+	}
+}
+
+// LoadLogo reads the PNG or JPEG logo of the path. The logo is loaded once, before the settings are handed to the components that draw QR codes.
+func LoadLogo(fileSystem afero.Fs, path string) (image.Image, error) {
+	file, err := fileSystem.Open(path)
+	if err != nil {
+		return nil, err
 	}
-	if card.Address() == nil {
-		address := vcard.Address{}
-		card.SetAddress(&address)
+	defer file.Close()
+
+	logo, _, err := image.Decode(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "The logo %s is neither a PNG nor a JPEG image", path)
 	}
+	return logo, nil
 }
@@ -1,8 +1,14 @@
 package repofile_test
 
 import (
+	"bytes"
+	"fmt"
 	"image"
+	"image/color"
 	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"strings"
 	"testing"
 
 	"github.com/spf13/afero"
@@ -16,8 +22,8 @@ import (
 )
 
 func createTestRepo(fs afero.Fs, settings configcli.CLIFileSettings) repofile.Repository {
-	cardCodec := vcardcodec.NewCodec()
-	qrCodec := qrcodec.NewCodec()
+	cardCodec := vcardcodec.NewCodec(settings.App)
+	qrCodec := qrcodec.NewCodec(&cardCodec)
 	repo := repofile.NewRepo(fs, &cardCodec, &qrCodec, settings.Files, settings.App)
 	return repo
 }
@@ -50,7 +56,7 @@ func TestMakeVCardInstanceFromExistingFile(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotEmpty(t, f)
 
-	expectedCard := testutil.CreateCard()
+	expectedCard := testutil.CreateConformingCard()
 	expectedContent := testutil.EncodeCard(expectedCard)
 
 	f.Write(expectedContent)
@@ -82,7 +88,7 @@ func TestWriteVCard(t *testing.T) {
 	settings.Files.WriteQRCodePath = "vcard.png"
 	repo := createTestRepo(filesystem, settings)
 
-	expectedCard := testutil.CreateCard()
+	expectedCard := testutil.CreateConformingCard()
 	err := repo.WriteVCard(expectedCard)
 	assert.NoError(t, err)
 
@@ -91,7 +97,7 @@ func TestWriteVCard(t *testing.T) {
 	assert.NotEmpty(t, actualCard)
 	assert.Equal(t, expectedCard, actualCard)
 
-	err = repo.WriteQRCode(expectedCard)
+	_, err = repo.WriteQRCode(expectedCard)
 	assert.NoError(t, err)
 	expectedCode := testutil.CreateQRCode(expectedCard, settings.App.QRSettings)
 	file, _ := filesystem.Open("vcard.png")
@@ -101,6 +107,254 @@ func TestWriteVCard(t *testing.T) {
 	assert.Equal(t, toRGBA(expectedCode), toRGBA(actualCode))
 }
 
+func TestStandardStreams(t *testing.T) {
+	filesystem := afero.NewMemMapFs()
+	settings := testutil.LoadTestSettings()
+	settings.Files.ReadVCardPath = configcli.StandardStream
+	settings.Files.WriteVCardPath = ""
+	settings.Files.WriteQRCodePath = configcli.StandardStream
+	repo := createTestRepo(filesystem, settings)
+
+	expectedCard := testutil.CreateConformingCard()
+	var stdout bytes.Buffer
+	repo.SetStandardStreams(bytes.NewReader(testutil.EncodeCard(expectedCard)), &stdout)
+
+	actualCard, err := repo.ReadOrCreateVCard()
+	assert.NoError(t, err)
+	assert.Equal(t, expectedCard, actualCard)
+
+	//only the QR code is written to stdout, no file is created
+	assert.NoError(t, repo.WriteVCard(actualCard))
+	_, err = repo.WriteQRCode(actualCard)
+	assert.NoError(t, err)
+	actualCode, format, err := image.Decode(&stdout)
+	assert.NoError(t, err)
+	assert.Equal(t, "png", format)
+	assert.Equal(t, toRGBA(testutil.CreateQRCode(expectedCard, settings.App.QRSettings)), toRGBA(actualCode))
+
+	files, err := afero.ReadDir(filesystem, ".")
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+
+	//the vCard instead of the QR code
+	settings.Files.WriteVCardPath = configcli.StandardStream
+	settings.Files.WriteQRCodePath = ""
+	repo = createTestRepo(filesystem, settings)
+	stdout.Reset()
+	repo.SetStandardStreams(nil, &stdout)
+
+	assert.NoError(t, repo.WriteVCard(expectedCard))
+	_, err = repo.WriteQRCode(expectedCard)
+	assert.NoError(t, err)
+	assert.Equal(t, testutil.ExpectedVCF, testutil.NormalizeNewLines(stdout.String()))
+}
+
+func TestConvertVCard(t *testing.T) {
+	filesystem := afero.NewMemMapFs()
+	settings := testutil.LoadTestSettings()
+	settings.Files.ReadVCardPath = "old.vcf"
+	settings.App.Convert = true
+	settings.App.VCardVersion = "3.0"
+	repo := createTestRepo(filesystem, settings)
+
+	afero.WriteFile(filesystem, "old.vcf", []byte("BEGIN:VCARD\r\nVERSION:4.0\r\nN:Doe;John;;;\r\nGENDER:M\r\nANNIVERSARY:20100612\r\nEND:VCARD\r\n"), 0644)
+
+	card, err := repo.ReadOrCreateVCard()
+	assert.NoError(t, err)
+	assert.Equal(t, "3.0", card.Value("VERSION"))
+	assert.Equal(t, "20100612", card.Value("X-ANNIVERSARY"))
+	assert.Nil(t, card.Get("GENDER"))
+	//the missing FN is taken from N
+	assert.Equal(t, "John Doe", card.Value("FN"))
+}
+
+func TestNormalizePhoneNumbers(t *testing.T) {
+	filesystem := afero.NewMemMapFs()
+	settings := testutil.LoadTestSettings()
+	settings.Files.ReadVCardPath = "contact.vcf"
+	settings.App.E164 = true
+	settings.App.Region = "DE"
+	repo := createTestRepo(filesystem, settings)
+
+	afero.WriteFile(filesystem, "contact.vcf", []byte("BEGIN:VCARD\r\nVERSION:3.0\r\nN:Doe;John;;;\r\nTEL;TYPE=cell:0171 / 123 45-67\r\nTEL;TYPE=work:+49(0)30 1234567\r\nTEL;TYPE=home:not a number\r\nEND:VCARD\r\n"), 0644)
+
+	card, err := repo.ReadOrCreateVCard()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"+491711234567", "+49301234567", "not a number"}, card.Values("TEL"))
+
+	//the numbers are kept without the flag
+	settings.App.E164 = false
+	repo = createTestRepo(filesystem, settings)
+	card, err = repo.ReadOrCreateVCard()
+	assert.NoError(t, err)
+	assert.Equal(t, "0171 / 123 45-67", card.Value("TEL"))
+}
+
+func TestReadVCardFromQRCodeImage(t *testing.T) {
+	filesystem := afero.NewMemMapFs()
+	settings := testutil.LoadTestSettings()
+	expectedCard := testutil.CreateConformingCard()
+
+	var pngData, jpegData bytes.Buffer
+	img := testutil.CreateQRCode(expectedCard, settings.App.QRSettings)
+	assert.NoError(t, png.Encode(&pngData, img))
+	assert.NoError(t, jpeg.Encode(&jpegData, img, &jpeg.Options{Quality: 90}))
+	afero.WriteFile(filesystem, "badge.png", pngData.Bytes(), 0644)
+	afero.WriteFile(filesystem, "badge.jpg", jpegData.Bytes(), 0644)
+
+	for _, path := range []string{"badge.png", "badge.jpg"} {
+		settings.Files.ReadVCardPath = path
+		repo := createTestRepo(filesystem, settings)
+		actualCard, err := repo.ReadOrCreateVCard()
+		assert.NoError(t, err)
+		assert.Equal(t, expectedCard, actualCard)
+	}
+}
+
+func TestWriteQRCodeSVG(t *testing.T) {
+	filesystem := afero.NewMemMapFs()
+	settings := testutil.LoadTestSettings()
+	settings.Files.QRCodeFormat = configcli.FormatSVG
+	settings.Files.WriteQRCodePath = "vcard.svg"
+	repo := createTestRepo(filesystem, settings)
+
+	card := testutil.CreateCard()
+	_, err := repo.WriteQRCode(card)
+	assert.NoError(t, err)
+
+	qrCodec := testutil.CreateQRCodec()
+	bitmap, _, err := qrCodec.Bitmap(card, settings.App.QRSettings)
+	assert.NoError(t, err)
+
+	content, err := afero.ReadFile(filesystem, "vcard.svg")
+	assert.NoError(t, err)
+	svg := string(content)
+	assert.Contains(t, svg, "<svg")
+	assert.Contains(t, svg, fmt.Sprintf(`viewBox="0 0 %d %d"`, len(bitmap), len(bitmap)))
+	assert.Contains(t, svg, fmt.Sprintf(`width="%d" height="%d"`, settings.App.QRSettings.Size, settings.App.QRSettings.Size))
+	assert.Contains(t, svg, `<rect width="`)
+	assert.Contains(t, svg, `fill="#ffffff"`)
+	assert.Contains(t, svg, `<path d="M`)
+	assert.Contains(t, svg, `fill="#000000"`)
+
+	//a transparent background is left out
+	settings.App.QRSettings.BackgroundColor = color.Transparent
+	repo = createTestRepo(filesystem, settings)
+	_, err = repo.WriteQRCode(card)
+	assert.NoError(t, err)
+
+	content, err = afero.ReadFile(filesystem, "vcard.svg")
+	assert.NoError(t, err)
+	assert.NotContains(t, string(content), "<rect")
+}
+
+func TestWriteQRCodePDF(t *testing.T) {
+	filesystem := afero.NewMemMapFs()
+	settings := testutil.LoadTestSettings()
+	settings.Files.QRCodeFormat = configcli.FormatPDF
+	settings.Files.WriteQRCodePath = "vcard.pdf"
+	settings.Files.PDFPage = configcli.PageSize{Width: 85, Height: 55}
+	settings.Files.PDFCaption = true
+	repo := createTestRepo(filesystem, settings)
+
+	card := testutil.CreateCard()
+	_, err := repo.WriteQRCode(card)
+	assert.NoError(t, err)
+
+	content, err := afero.ReadFile(filesystem, "vcard.pdf")
+	assert.NoError(t, err)
+	pdf := string(content)
+	assert.True(t, strings.HasPrefix(pdf, "%PDF-1.4\n"))
+	assert.True(t, strings.HasSuffix(pdf, "%%EOF\n"))
+	assert.Contains(t, pdf, "/MediaBox [0 0 240.945 155.906]")
+	assert.Contains(t, pdf, " re\n")
+	assert.Contains(t, pdf, "(Honorific prefix Given name Additional name Family name Honorific suffix) Tj")
+	assert.Contains(t, pdf, "(Job title) Tj")
+	assert.Contains(t, pdf, "(Organization or company, Department) Tj")
+
+	//the cross reference table points to the objects
+	var xref int
+	_, err = fmt.Sscanf(pdf[strings.LastIndex(pdf, "startxref"):], "startxref\n%d", &xref)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(pdf[xref:], "xref\n0 6\n"))
+	entries := strings.Split(pdf[xref:], "\n")[3:8]
+	for i, entry := range entries {
+		var offset int
+		_, err = fmt.Sscanf(entry, "%d", &offset)
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(pdf[offset:], fmt.Sprintf("%d 0 obj", i+1)))
+	}
+
+	//without caption there is no text
+	settings.Files.PDFCaption = false
+	repo = createTestRepo(filesystem, settings)
+	_, err = repo.WriteQRCode(card)
+	assert.NoError(t, err)
+
+	content, err = afero.ReadFile(filesystem, "vcard.pdf")
+	assert.NoError(t, err)
+	assert.NotContains(t, string(content), "Tj")
+}
+
+func TestWriteQRCodeLogo(t *testing.T) {
+	filesystem := afero.NewMemMapFs()
+	settings := testutil.LoadTestSettings()
+	settings.Files.WriteQRCodePath = "vcard.png"
+
+	card := testutil.CreateCard()
+
+	//the logo file does not exist
+	_, err := repofile.LoadLogo(filesystem, "logo.png")
+	assert.Error(t, err)
+
+	logo := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	draw.Draw(logo, logo.Bounds(), image.NewUniform(color.RGBA{B: 255, A: 255}), image.Point{}, draw.Src)
+	file, err := filesystem.Create("logo.png")
+	assert.NoError(t, err)
+	assert.NoError(t, png.Encode(file, logo))
+	file.Close()
+
+	settings.App.QRSettings.Logo, err = repofile.LoadLogo(filesystem, "logo.png")
+	assert.NoError(t, err)
+	repo := createTestRepo(filesystem, settings)
+	_, err = repo.WriteQRCode(card)
+	assert.NoError(t, err)
+
+	expectedCode := testutil.CreateQRCode(card, settings.App.QRSettings)
+	file, err = filesystem.Open("vcard.png")
+	assert.NoError(t, err)
+	actualCode, _, err := image.Decode(file)
+	assert.NoError(t, err)
+	assert.Equal(t, toRGBA(expectedCode), toRGBA(actualCode))
+
+	//the logo is embedded into the SVG
+	settings.Files.QRCodeFormat = configcli.FormatSVG
+	settings.Files.WriteQRCodePath = "vcard.svg"
+	repo = createTestRepo(filesystem, settings)
+	_, err = repo.WriteQRCode(card)
+	assert.NoError(t, err)
+	content, err := afero.ReadFile(filesystem, "vcard.svg")
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `xmlns:xlink="http://www.w3.org/1999/xlink"`)
+	assert.Contains(t, string(content), ` xlink:href="data:image/png;base64,`)
+
+	//the logo is embedded into the PDF
+	settings.Files.QRCodeFormat = configcli.FormatPDF
+	settings.Files.WriteQRCodePath = "vcard.pdf"
+	repo = createTestRepo(filesystem, settings)
+	_, err = repo.WriteQRCode(card)
+	assert.NoError(t, err)
+	content, err = afero.ReadFile(filesystem, "vcard.pdf")
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "/Im1 Do")
+	assert.Contains(t, string(content), "/Subtype /Image")
+
+	//a file that is not an image is refused
+	afero.WriteFile(filesystem, "logo.png", []byte("no image"), 0644)
+	_, err = repofile.LoadLogo(filesystem, "logo.png")
+	assert.Error(t, err)
+}
+
 func toRGBA(img image.Image) *image.RGBA {
 	b := img.Bounds()
 	rgba := image.NewRGBA(b)
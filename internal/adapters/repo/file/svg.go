@@ -0,0 +1,72 @@
+package repofile
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+
+	qrcodec "github.com/ulfschneider/qrvc/internal/adapters/codec/qr"
+	"github.com/ulfschneider/qrvc/internal/application/config"
+)
+
+// encodeSVG writes the QR code module matrix as a scalable vector graphic.
+// Each module is one unit of the view box.
+func encodeSVG(w io.Writer, bitmap [][]bool, settings config.QRCodeSettings) error {
+	modules := len(bitmap)
+	logoArea := qrcodec.LogoArea(bitmap, settings)
+	bitmap = withoutArea(bitmap, logoArea)
+
+	var svg strings.Builder
+	svg.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" version="1.1" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n",
+		settings.Size, settings.Size, modules, modules)
+
+	if fill := svgFill(settings.BackgroundColor); fill != "" {
+		fmt.Fprintf(&svg, `<rect width="%d" height="%d"%s/>`+"\n", modules, modules, fill)
+	}
+
+	var path strings.Builder
+	for _, run := range moduleRuns(bitmap) {
+		fmt.Fprintf(&path, "M%d %dh%dv1h-%dz", run.x, run.y, run.length, run.length)
+	}
+
+	if fill := svgFill(settings.ForegroundColor); fill != "" && path.Len() > 0 {
+		fmt.Fprintf(&svg, `<path d="%s"%s/>`+"\n", path.String(), fill)
+	}
+
+	if !logoArea.Empty() {
+		var logo bytes.Buffer
+		if err := png.Encode(&logo, settings.Logo); err != nil {
+			return err
+		}
+		fmt.Fprintf(&svg, `<image x="%g" y="%g" width="%d" height="%d" preserveAspectRatio="xMidYMid meet" xlink:href="data:image/png;base64,%s"/>`+"\n",
+			float64(logoArea.Min.X)+0.5, float64(logoArea.Min.Y)+0.5, logoArea.Dx()-1, logoArea.Dy()-1, base64.StdEncoding.EncodeToString(logo.Bytes()))
+	}
+
+	svg.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, svg.String())
+	return err
+}
+
+// svgFill returns the fill attributes for the given color, or an empty string when the color is fully transparent.
+func svgFill(c color.Color) string {
+	if c == nil {
+		return ""
+	}
+
+	nrgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+	if nrgba.A == 0 {
+		return ""
+	}
+
+	fill := fmt.Sprintf(` fill="#%02x%02x%02x"`, nrgba.R, nrgba.G, nrgba.B)
+	if nrgba.A < 0xff {
+		fill += fmt.Sprintf(` fill-opacity="%.3f"`, float64(nrgba.A)/0xff)
+	}
+	return fill
+}
@@ -1,21 +1,118 @@
 package config
 
 import (
+	"image"
 	"image/color"
 
 	"github.com/skip2/go-qrcode"
 )
 
 type Settings struct {
-	Silent       bool
-	VCardVersion string
-	QRSettings   QRCodeSettings
+	Silent        bool
+	VCardVersion  string
+	Convert       bool
+	Terminal      bool
+	TerminalStyle string
+	Jobs          int
+	Info          string
+	DPI           int
+	Region        string
+	E164          bool
+	NameOrder     string
+	//KeepEmptyFields writes the vCard with its fields without value and the full skeleton, in the QR code and in the vCard file
+	KeepEmptyFields bool
+	QRSettings      QRCodeSettings
 }
 
+// The vCard versions that can be written.
+const (
+	VCardVersion21 = "2.1"
+	VCardVersion30 = "3.0"
+	VCardVersion40 = "4.0"
+)
+
+var VCardVersions = []string{VCardVersion21, VCardVersion30, VCardVersion40}
+
+// The styles to draw a QR code in the terminal.
+const (
+	TerminalUnicode = "unicode"
+	TerminalASCII   = "ascii"
+)
+
+// The orders of the name components in the formatted name, the family name first is common in East Asia and Hungary.
+const (
+	NameOrderGivenFirst  = "given-first"
+	NameOrderFamilyFirst = "family-first"
+)
+
+var NameOrders = []string{NameOrderGivenFirst, NameOrderFamilyFirst}
+
+// The forms of the QR code information.
+const (
+	InfoText = "text"
+	InfoJSON = "json"
+)
+
+var InfoFormats = []string{InfoText, InfoJSON}
+
 type QRCodeSettings struct {
-	Border          bool
-	Size            int
-	RecoveryLevel   qrcode.RecoveryLevel
-	BackgroundColor color.Color
-	ForegroundColor color.Color
+	Border            bool
+	Size              int
+	RecoveryLevel     qrcode.RecoveryLevel
+	AutoRecoveryLevel bool
+	MaxVersion        int
+	Payload           string
+	TrimOrder         []string
+	Verify            bool
+	MinContrast       float64
+	BackgroundColor   color.Color
+	ForegroundColor   color.Color
+	Logo              image.Image
+	LogoSize          float64
+}
+
+// The payloads a QR code can carry. Auto picks the payload that gives the smallest QR code.
+const (
+	PayloadVCard  = "vcard"
+	PayloadMeCard = "mecard"
+	PayloadAuto   = "auto"
+)
+
+var Payloads = []string{PayloadVCard, PayloadMeCard, PayloadAuto}
+
+// MaxQRCodeVersion is the largest QR code version defined by the QR code standard.
+const MaxQRCodeVersion = 40
+
+// DefaultTrimOrder is the order in which fields, or parts of fields like ADR.pobox, are left out of the QR code
+// when the card does not fit into the maximum version. The fields that identify and reach a person are never left out by default.
+var DefaultTrimOrder = []string{
+	"PHOTO", "LOGO", "SOUND", "KEY", "NOTE",
+	"ADR.pobox", "ADR.extended", "N.suffix", "N.prefix", "N.additional", "ORG.unit",
+	"ROLE", "TITLE", "NICKNAME", "BDAY", "URL", "ADR",
+}
+
+// TrimComponents are the parts of structured fields that can be left out of the QR code on their own, with their position in the field value.
+var TrimComponents = map[string]map[string]int{
+	"N":   {"additional": 2, "prefix": 3, "suffix": 4},
+	"ADR": {"pobox": 0, "extended": 1, "region": 4, "country": 6},
+	"ORG": {"unit": 1},
+}
+
+// EffectiveMaxVersion returns the maximum QR code version of the settings, which is the largest version of the standard when no maximum is given.
+func EffectiveMaxVersion(settings QRCodeSettings) int {
+	if settings.MaxVersion <= 0 || settings.MaxVersion > MaxQRCodeVersion {
+		return MaxQRCodeVersion
+	}
+	return settings.MaxVersion
+}
+
+// RecoveryLevelNames are the names of the QR code error recovery levels, from the lowest to the highest level.
+var RecoveryLevelNames = []string{"low", "medium", "high", "highest"}
+
+// RecoveryLevelName returns the name of the given QR code error recovery level.
+func RecoveryLevelName(level qrcode.RecoveryLevel) string {
+	if int(level) >= 0 && int(level) < len(RecoveryLevelNames) {
+		return RecoveryLevelNames[level]
+	}
+	return "unknown"
 }
@@ -2,6 +2,7 @@ package ports
 
 import (
 	"image"
+	"iter"
 
 	"github.com/CycloneDX/cyclonedx-go"
 	"github.com/emersion/go-vcard"
@@ -12,19 +13,62 @@ type VCardEditor interface {
 	Edit(card vcard.Card) error
 }
 
+type QRCodePreviewer interface {
+	Preview(card vcard.Card) error
+	Print(card vcard.Card) (QRCodeInfo, error)
+	Info(info QRCodeInfo) error
+}
+
 type Repository interface {
 	ReadOrCreateVCard() (vcard.Card, error)
 	WriteVCard(card vcard.Card) error
-	WriteQRCode(card vcard.Card) error
+	WriteQRCode(card vcard.Card) (QRCodeInfo, error)
+	ReadVCardBatch() (iter.Seq[BatchCard], error)
+	WriteBatchVCard(card vcard.Card, name string) error
+	WriteBatchQRCode(card vcard.Card, name string) (QRCodeInfo, error)
+	ReleaseBatchCard(card vcard.Card)
+}
+
+// BatchCard is one card of a batch run. Source names the file the card was read from,
+// Name is the unique name for the output files of the card, Err is set when the card could not be read.
+type BatchCard struct {
+	Source string
+	Name   string
+	Card   vcard.Card
+	Err    error
 }
 
 type QRCodec interface {
-	Encode(card vcard.Card, settings config.QRCodeSettings) (image.Image, error)
+	Encode(card vcard.Card, settings config.QRCodeSettings) (image.Image, QRCodeInfo, error)
+	Bitmap(card vcard.Card, settings config.QRCodeSettings) ([][]bool, QRCodeInfo, error)
+	Decode(img image.Image) (vcard.Card, error)
+	Fits(card vcard.Card, settings config.QRCodeSettings) bool
+}
+
+// QRCodeInfo describes the symbol of a QR code and the smallest size to print it reliably at a resolution.
+// Modules is the width of the symbol without the quiet zone, the print size includes the quiet zone.
+// The QR codec describes the symbol it has encoded, the print size is added for the resolution it is shown for.
+// Notes tell what the QR codec has changed to make the card fit, they are shown once the QR code has been written.
+type QRCodeInfo struct {
+	Version       int      `json:"version"`
+	Modules       int      `json:"modules"`
+	RecoveryLevel string   `json:"recoveryLevel"`
+	Mask          int      `json:"mask"`
+	Payload       string   `json:"payload"`
+	PayloadBytes  int      `json:"payloadBytes"`
+	DPI           int      `json:"dpi"`
+	ModuleDots    int      `json:"moduleDots"`
+	ModuleSize    float64  `json:"moduleSizeMM"`
+	PrintSize     float64  `json:"printSizeMM"`
+	Notes         []string `json:"notes,omitempty"`
 }
 
 type VCardCodec interface {
 	Encode(card vcard.Card) ([]byte, error)
 	Decode(vcf []byte) (vcard.Card, error)
+	DecodeAll(vcf []byte) ([]vcard.Card, error)
+	Convert(card vcard.Card, version string) (vcard.Card, []string)
+	Forget(card vcard.Card)
 }
 
 type VersionProvider interface {
@@ -32,8 +76,8 @@ type VersionProvider interface {
 }
 
 type UserNotifier interface {
-	Notify(message ...string)
-	NotifyLoud(message ...string)
+	Notify(message ...any)
+	NotifyLoud(message ...any)
 	Notifyf(format string, values ...any)
 	NotifyfLoud(format string, values ...any)
 	Section()
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/ulfschneider/qrvc/internal/application/config"
+	"github.com/ulfschneider/qrvc/internal/application/ports"
+)
+
+type BatchService struct {
+	settings     config.Settings
+	repo         ports.Repository
+	userNotifier ports.UserNotifier
+}
+
+func NewBatchService(settings config.Settings, repo ports.Repository, userNotifier ports.UserNotifier) BatchService {
+	return BatchService{
+		settings:     settings,
+		repo:         repo,
+		userNotifier: userNotifier,
+	}
+}
+
+// batchJob is a card of the batch together with its position in the input.
+type batchJob struct {
+	index int
+	card  ports.BatchCard
+}
+
+// TransformCards writes a vCard and a QR code for every card of the batch input, or only the vCard when converting.
+// Reading the input overlaps with a pool of workers that encode and write the cards.
+// A failing card does not stop the batch, all failures are reported in the summary in input order.
+// Cancelling the context stops the batch after the cards that are in progress.
+func (bs *BatchService) TransformCards(ctx context.Context) error {
+	cards, err := bs.repo.ReadVCardBatch()
+	if err != nil {
+		return err
+	}
+
+	jobs := make(chan batchJob)
+	go func() {
+		defer close(jobs)
+		index := 0
+		for card := range cards {
+			select {
+			case jobs <- batchJob{index: index, card: card}:
+				index++
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var mutex sync.Mutex
+	results := map[int]ports.BatchCard{}
+
+	var workers sync.WaitGroup
+	for range max(1, bs.settings.Jobs) {
+		workers.Go(func() {
+			for job := range jobs {
+				if job.card.Err == nil {
+					if ctx.Err() != nil {
+						job.card.Err = ctx.Err()
+					} else {
+						job.card.Err = bs.transformCard(job.card)
+					}
+				}
+				if job.card.Card != nil {
+					bs.repo.ReleaseBatchCard(job.card.Card)
+				}
+				mutex.Lock()
+				results[job.index] = job.card
+				mutex.Unlock()
+			}
+		})
+	}
+	workers.Wait()
+
+	failures := []error{}
+	for index := range len(results) {
+		if card := results[index]; card.Err != nil {
+			failures = append(failures, fmt.Errorf("%s: %w", card.Source, card.Err))
+		}
+	}
+
+	err = bs.summarize(len(results), failures)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+func (bs *BatchService) transformCard(item ports.BatchCard) error {
+	if !bs.settings.Convert {
+		//a card with invalid values is not written at all
+		if err := validateCard(item.Card, bs.settings.Region, item.Source, bs.userNotifier); err != nil {
+			return err
+		}
+	}
+	if err := bs.repo.WriteBatchVCard(item.Card, item.Name); err != nil {
+		return err
+	}
+	if bs.settings.Convert {
+		//a conversion only writes the vCard
+		return nil
+	}
+	info, err := bs.repo.WriteBatchQRCode(item.Card, item.Name)
+	if err != nil {
+		return err
+	}
+	notifyNotes(info, item.Source, bs.userNotifier)
+	return nil
+}
+
+func (bs *BatchService) summarize(total int, failures []error) error {
+	bs.userNotifier.Section()
+	bs.userNotifier.Notifyf("%s of %s contacts have been converted", total-len(failures), total)
+
+	if len(failures) == 0 {
+		return nil
+	}
+
+	for _, failure := range failures {
+		bs.userNotifier.Notify(failure)
+	}
+	return fmt.Errorf("%d of %d contacts could not be converted", len(failures), total)
+}
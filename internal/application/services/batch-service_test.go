@@ -0,0 +1,153 @@
+package services_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"iter"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/emersion/go-vcard"
+	"github.com/stretchr/testify/assert"
+
+	notifiercli "github.com/ulfschneider/qrvc/internal/adapters/notifier/cli"
+	"github.com/ulfschneider/qrvc/internal/application/config"
+	"github.com/ulfschneider/qrvc/internal/application/ports"
+	"github.com/ulfschneider/qrvc/internal/application/services"
+)
+
+type testBatchRepo struct {
+	ports.Repository
+	cards    []ports.BatchCard
+	mutex    sync.Mutex
+	written  map[string]int
+	released int
+	notes    []string
+	cancel   context.CancelFunc
+}
+
+func (r *testBatchRepo) ReadVCardBatch() (iter.Seq[ports.BatchCard], error) {
+	return func(yield func(ports.BatchCard) bool) {
+		for _, card := range r.cards {
+			if !yield(card) {
+				return
+			}
+		}
+	}, nil
+}
+
+func (r *testBatchRepo) WriteBatchVCard(card vcard.Card, name string) error {
+	if name == "failing" {
+		return errors.New("write failed")
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.written[name]++
+	return nil
+}
+
+func (r *testBatchRepo) WriteBatchQRCode(card vcard.Card, name string) (ports.QRCodeInfo, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.written[name]++
+	if r.cancel != nil {
+		r.cancel()
+	}
+	return ports.QRCodeInfo{Notes: r.notes}, nil
+}
+
+func (r *testBatchRepo) ReleaseBatchCard(card vcard.Card) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.released++
+}
+
+func createTestBatch(n int) []ports.BatchCard {
+	cards := []ports.BatchCard{}
+	for i := range n {
+		name := string(rune('a'+i%26)) + string(rune('a'+i/26))
+		cards = append(cards, ports.BatchCard{Source: name + ".vcf", Name: name, Card: vcard.Card{}})
+	}
+	return cards
+}
+
+func TestBatchService(t *testing.T) {
+	repo := &testBatchRepo{cards: createTestBatch(100), written: map[string]int{}}
+	repo.cards[10].Name = "failing"
+	repo.cards[20].Err = errors.New("read failed")
+
+	userNotifier := notifiercli.NewUserNotifier()
+	batchService := services.NewBatchService(config.Settings{Jobs: 8}, repo, &userNotifier)
+
+	err := batchService.TransformCards(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, "2 of 100 contacts could not be converted", err.Error())
+
+	//every other card has been written completely
+	assert.Len(t, repo.written, 98)
+	for _, count := range repo.written {
+		assert.Equal(t, 2, count)
+	}
+
+	//every card is released, whether it has been written or not
+	assert.Equal(t, 100, repo.released)
+}
+
+func TestBatchServiceValidation(t *testing.T) {
+	repo := &testBatchRepo{cards: createTestBatch(3), written: map[string]int{}}
+	repo.cards[1].Card.SetValue(vcard.FieldEmail, "jon@example")
+	repo.cards[2].Card.SetValue(vcard.FieldTelephone, "0171 1234567")
+
+	userNotifier := notifiercli.NewUserNotifier()
+	batchService := services.NewBatchService(config.Settings{Jobs: 1}, repo, &userNotifier)
+
+	//the invalid mail address fails before anything is written, the national phone number without region is only reported
+	err := batchService.TransformCards(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, "1 of 3 contacts could not be converted", err.Error())
+	assert.Len(t, repo.written, 2)
+
+	//with a region, the national phone number is checked and valid
+	repo.written = map[string]int{}
+	batchService = services.NewBatchService(config.Settings{Jobs: 1, Region: "DE"}, repo, &userNotifier)
+	err = batchService.TransformCards(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, "1 of 3 contacts could not be converted", err.Error())
+	assert.Len(t, repo.written, 2)
+
+	//a conversion does not check the values
+	repo.written = map[string]int{}
+	batchService = services.NewBatchService(config.Settings{Jobs: 1, Convert: true}, repo, &userNotifier)
+	assert.NoError(t, batchService.TransformCards(context.Background()))
+	assert.Len(t, repo.written, 3)
+}
+
+func TestBatchServiceNotes(t *testing.T) {
+	repo := &testBatchRepo{cards: createTestBatch(2), written: map[string]int{}, notes: []string{"The recovery level has been lowered to low"}}
+
+	var output bytes.Buffer
+	userNotifier := notifiercli.NewUserNotifier()
+	userNotifier.SetOutput(&output)
+	t.Cleanup(func() { userNotifier.SetOutput(os.Stdout) })
+	batchService := services.NewBatchService(config.Settings{Jobs: 1}, repo, &userNotifier)
+
+	//the notes of each QR code are shown once, with the source of the card
+	assert.NoError(t, batchService.TransformCards(context.Background()))
+	assert.Equal(t, 1, strings.Count(output.String(), "aa.vcf: The recovery level has been lowered to low\n"))
+	assert.Equal(t, 1, strings.Count(output.String(), "ba.vcf: The recovery level has been lowered to low\n"))
+}
+
+func TestBatchServiceCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	repo := &testBatchRepo{cards: createTestBatch(100), written: map[string]int{}, cancel: cancel}
+
+	userNotifier := notifiercli.NewUserNotifier()
+	batchService := services.NewBatchService(config.Settings{Jobs: 1}, repo, &userNotifier)
+
+	err := batchService.TransformCards(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, len(repo.written), 100)
+}
@@ -1,22 +1,32 @@
 package services
 
 import (
+	"errors"
+	"fmt"
+
+	"github.com/emersion/go-vcard"
+
 	"github.com/ulfschneider/qrvc/internal/application/config"
 	"github.com/ulfschneider/qrvc/internal/application/ports"
+	qrcard "github.com/ulfschneider/qrvc/internal/domain"
 )
 
 type QRCardService struct {
-	settings config.Settings
-	repo     ports.Repository
-	editor   ports.VCardEditor
+	settings     config.Settings
+	repo         ports.Repository
+	editor       ports.VCardEditor
+	previewer    ports.QRCodePreviewer
+	userNotifier ports.UserNotifier
 }
 
-func NewQRCardService(settings config.Settings, repo ports.Repository, editor ports.VCardEditor) QRCardService {
+func NewQRCardService(settings config.Settings, repo ports.Repository, editor ports.VCardEditor, previewer ports.QRCodePreviewer, userNotifier ports.UserNotifier) QRCardService {
 
 	return QRCardService{
-		settings: settings,
-		repo:     repo,
-		editor:   editor,
+		settings:     settings,
+		repo:         repo,
+		editor:       editor,
+		previewer:    previewer,
+		userNotifier: userNotifier,
 	}
 }
 
@@ -27,19 +37,80 @@ func (qs *QRCardService) TransformCard() error {
 		return err
 	}
 
-	if qs.settings.Silent == false {
+	if qs.settings.Silent == false && qs.settings.Convert == false {
 		if err = qs.editor.Edit(card); err != nil {
 			return err
 		}
+	} else if qs.settings.Convert == false {
+		//the editor checks the input while it is given, without the editor the card is checked before anything is written
+		if err = validateCard(card, qs.settings.Region, "", qs.userNotifier); err != nil {
+			return err
+		}
 	}
 
 	if err = qs.repo.WriteVCard(card); err != nil {
 		return err
 	}
 
-	if err = qs.repo.WriteQRCode(card); err != nil {
+	if qs.settings.Convert {
+		//a conversion only writes the vCard
+		return nil
+	}
+
+	//the info describes the QR code that has been printed or written
+	var info ports.QRCodeInfo
+	if qs.settings.Terminal {
+		if info, err = qs.previewer.Print(card); err != nil {
+			return err
+		}
+	} else if info, err = qs.repo.WriteQRCode(card); err != nil {
 		return err
 	}
+	//what has been changed to fit the card is told once, for the QR code that has been written or printed
+	notifyNotes(info, "", qs.userNotifier)
+
+	if qs.settings.Info != "" {
+		if err = qs.previewer.Info(info); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
+
+// notifyNotes shows the notes of a QR code that has been written or printed. The source names the file of the card, it is empty for a single card.
+func notifyNotes(info ports.QRCodeInfo, source string, userNotifier ports.UserNotifier) {
+	for _, note := range info.Notes {
+		if source != "" {
+			note = fmt.Sprintf("%s: %s", source, note)
+		}
+		userNotifier.Notify(note)
+	}
+}
+
+// validateCard checks the values of the card before anything is written, an invalid value fails the card.
+// The values that could not be checked, like phone numbers in national format without a region, are only reported.
+// The source names the file of the card in the reports, it is empty for a single card.
+func validateCard(card vcard.Card, region, source string, userNotifier ports.UserNotifier) error {
+	err := qrcard.ValidateCard(card, region)
+	if err == nil {
+		return nil
+	}
+
+	problems := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		problems = joined.Unwrap()
+	}
+	failures := []error{}
+	for _, problem := range problems {
+		if !qrcard.IsUnchecked(problem) {
+			failures = append(failures, problem)
+			continue
+		}
+		if source != "" {
+			problem = fmt.Errorf("%s: %w", source, problem)
+		}
+		userNotifier.Notify(problem)
+	}
+	return errors.Join(failures...)
+}
@@ -0,0 +1,5 @@
+package qrcard
+
+// PostalCodeFormats and CountryNames give the tests the countries whose postal codes are checked.
+var PostalCodeFormats = postalCodeFormats
+var CountryNames = countryNames
@@ -0,0 +1,82 @@
+package qrcard
+
+import (
+	"strings"
+
+	"github.com/emersion/go-vcard"
+)
+
+// FormatName fills the placeholders of the template with values of the card.
+// Known placeholders are {family}, {given}, {additional}, {prefix}, {suffix}, {fn}, {org} and {title}.
+// Separators that are left over at the start or end, because of empty values, are removed.
+func FormatName(card vcard.Card, template string) string {
+	name := card.Name()
+	if name == nil {
+		name = &vcard.Name{}
+	}
+
+	organization, _, _ := strings.Cut(card.Value(vcard.FieldOrganization), ";")
+
+	replacer := strings.NewReplacer(
+		"{family}", name.FamilyName,
+		"{given}", name.GivenName,
+		"{additional}", name.AdditionalName,
+		"{prefix}", name.HonorificPrefix,
+		"{suffix}", name.HonorificSuffix,
+		"{fn}", card.Value(vcard.FieldFormattedName),
+		"{org}", organization,
+		"{title}", card.Value(vcard.FieldTitle),
+	)
+
+	return strings.Trim(replacer.Replace(template), " -_.")
+}
+
+// FormattedName returns the FN of the card, or joins the components of N in the order prefix, given, additional, family and suffix when there is no FN.
+func FormattedName(card vcard.Card) string {
+	if fn := strings.TrimSpace(card.Value(vcard.FieldFormattedName)); fn != "" {
+		return fn
+	}
+	return JoinName(card.Name(), false)
+}
+
+// JoinName joins the components of the name in the order prefix, given, additional, family and suffix,
+// or in the order prefix, family, given, additional and suffix for locales that put the family name first.
+func JoinName(name *vcard.Name, familyFirst bool) string {
+	if name == nil {
+		return ""
+	}
+
+	components := []string{name.HonorificPrefix, name.GivenName, name.AdditionalName, name.FamilyName, name.HonorificSuffix}
+	if familyFirst {
+		components = []string{name.HonorificPrefix, name.FamilyName, name.GivenName, name.AdditionalName, name.HonorificSuffix}
+	}
+	return strings.Join(strings.Fields(strings.Join(components, " ")), " ")
+}
+
+// CompleteFormattedName sets the FN of the card from the components of N when the card has no FN, a given FN is kept.
+func CompleteFormattedName(card vcard.Card, familyFirst bool) {
+	if strings.TrimSpace(card.Value(vcard.FieldFormattedName)) != "" {
+		return
+	}
+	if fn := JoinName(card.Name(), familyFirst); fn != "" {
+		card.SetValue(vcard.FieldFormattedName, fn)
+	}
+}
+
+// FormattedNameAgrees tells whether the FN of the card contains the given and the family name of N, in any order and case.
+// Prefixes, suffixes and additional names are often left out of an FN, like Dr. in John Doe, and are not compared.
+// A card without FN or without N agrees.
+func FormattedNameAgrees(card vcard.Card) bool {
+	fn := strings.ToLower(card.Value(vcard.FieldFormattedName))
+	name := card.Name()
+	if strings.TrimSpace(fn) == "" || name == nil {
+		return true
+	}
+
+	for _, component := range []string{name.GivenName, name.FamilyName} {
+		if !strings.Contains(fn, strings.ToLower(strings.TrimSpace(component))) {
+			return false
+		}
+	}
+	return true
+}
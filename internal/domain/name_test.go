@@ -0,0 +1,77 @@
+package qrcard_test
+
+import (
+	"testing"
+
+	"github.com/emersion/go-vcard"
+	"github.com/stretchr/testify/assert"
+	qrcard "github.com/ulfschneider/qrvc/internal/domain"
+	testutil "github.com/ulfschneider/qrvc/internal/test/util"
+)
+
+func TestFormatName(t *testing.T) {
+	card := testutil.CreateCard()
+
+	assert.Equal(t, "Family name-Given name", qrcard.FormatName(card, "{family}-{given}"))
+	assert.Equal(t, "Organization or company_Job title", qrcard.FormatName(card, "{org}_{title}"))
+	assert.Equal(t, "contact {unknown}", qrcard.FormatName(card, "contact {unknown}"))
+
+	//separators of empty values are trimmed
+	card.SetName(&vcard.Name{FamilyName: "Family name"})
+	assert.Equal(t, "Family name", qrcard.FormatName(card, "{family}-{given}"))
+
+	//a card without a name
+	assert.Equal(t, "", qrcard.FormatName(vcard.Card{}, "{family}-{given}"))
+}
+
+func TestFormattedName(t *testing.T) {
+	card := vcard.Card{}
+	card.SetName(&vcard.Name{HonorificPrefix: "Lady", GivenName: "Ada", FamilyName: "Lovelace"})
+	assert.Equal(t, "Lady Ada Lovelace", qrcard.FormattedName(card))
+
+	card.SetName(&vcard.Name{GivenName: "Ada", FamilyName: "Lovelace"})
+	assert.Equal(t, "Ada Lovelace", qrcard.FormattedName(card))
+
+	//an existing FN wins
+	card.SetValue(vcard.FieldFormattedName, "Countess of Lovelace")
+	assert.Equal(t, "Countess of Lovelace", qrcard.FormattedName(card))
+
+	assert.Equal(t, "", qrcard.FormattedName(vcard.Card{}))
+}
+
+func TestJoinName(t *testing.T) {
+	name := &vcard.Name{HonorificPrefix: "Dr.", GivenName: "János", FamilyName: "Nagy"}
+	assert.Equal(t, "Dr. János Nagy", qrcard.JoinName(name, false))
+	assert.Equal(t, "Dr. Nagy János", qrcard.JoinName(name, true))
+	assert.Equal(t, "", qrcard.JoinName(nil, false))
+}
+
+func TestCompleteFormattedName(t *testing.T) {
+	card := vcard.Card{}
+	card.SetName(&vcard.Name{GivenName: "Ada", FamilyName: "Lovelace"})
+	qrcard.CompleteFormattedName(card, true)
+	assert.Equal(t, "Lovelace Ada", card.Value(vcard.FieldFormattedName))
+	assert.True(t, qrcard.FormattedNameAgrees(card))
+
+	//a given FN is kept
+	card.SetValue(vcard.FieldFormattedName, "Countess of Lovelace")
+	qrcard.CompleteFormattedName(card, false)
+	assert.Equal(t, "Countess of Lovelace", card.Value(vcard.FieldFormattedName))
+	assert.False(t, qrcard.FormattedNameAgrees(card))
+
+	card.SetValue(vcard.FieldFormattedName, "LOVELACE, Ada")
+	assert.True(t, qrcard.FormattedNameAgrees(card))
+
+	//prefixes, suffixes and additional names may be left out
+	card.SetName(&vcard.Name{GivenName: "John", FamilyName: "Doe", HonorificPrefix: "Dr.", AdditionalName: "Q.", HonorificSuffix: "Jr."})
+	card.SetValue(vcard.FieldFormattedName, "John Doe")
+	assert.True(t, qrcard.FormattedNameAgrees(card))
+	card.SetValue(vcard.FieldFormattedName, "Dr. John Smith")
+	assert.False(t, qrcard.FormattedNameAgrees(card))
+
+	//a card without any name gets no FN
+	card = vcard.Card{}
+	qrcard.CompleteFormattedName(card, false)
+	assert.Nil(t, card.Get(vcard.FieldFormattedName))
+	assert.True(t, qrcard.FormattedNameAgrees(card))
+}
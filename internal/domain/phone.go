@@ -0,0 +1,46 @@
+package qrcard
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/emersion/go-vcard"
+	"github.com/nyaruka/phonenumbers"
+)
+
+// PhoneNumberChange is a phone number that has been rewritten into E.164 format.
+type PhoneNumberChange struct {
+	From string
+	To   string
+}
+
+// NormalizePhoneNumber returns the phone number in the international E.164 format, like +491711234567.
+// Numbers in national format are read for the region, which is a two letter country code.
+func NormalizePhoneNumber(phoneNumber, region string) (string, error) {
+	if err := ValidatePhoneNumber(phoneNumber, region); err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(phoneNumber) == "" {
+		return "", errors.New("The phone number is empty")
+	}
+	parsed, err := phonenumbers.Parse(phoneNumber, strings.ToUpper(region))
+	if err != nil {
+		return "", err
+	}
+	return phonenumbers.Format(parsed, phonenumbers.E164), nil
+}
+
+// NormalizePhoneNumbers rewrites the phone numbers of the card into E.164 format and returns every value that has been changed.
+// Phone numbers that are not valid are left as they are, ValidateCard reports them.
+func NormalizePhoneNumbers(card vcard.Card, region string) []PhoneNumberChange {
+	changes := []PhoneNumberChange{}
+	for _, field := range card[vcard.FieldTelephone] {
+		normalized, err := NormalizePhoneNumber(field.Value, region)
+		if err != nil || normalized == field.Value {
+			continue
+		}
+		changes = append(changes, PhoneNumberChange{From: field.Value, To: normalized})
+		field.Value = normalized
+	}
+	return changes
+}
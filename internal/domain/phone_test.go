@@ -0,0 +1,40 @@
+package qrcard_test
+
+import (
+	"testing"
+
+	"github.com/emersion/go-vcard"
+	"github.com/stretchr/testify/assert"
+	qrcard "github.com/ulfschneider/qrvc/internal/domain"
+)
+
+func TestNormalizePhoneNumber(t *testing.T) {
+	normalized, err := qrcard.NormalizePhoneNumber("0171 / 123 45-67", "DE")
+	assert.NoError(t, err)
+	assert.Equal(t, "+491711234567", normalized)
+
+	normalized, err = qrcard.NormalizePhoneNumber("+49 (0)171 1234567", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "+491711234567", normalized)
+
+	normalized, err = qrcard.NormalizePhoneNumber("(650) 253-0000", "US")
+	assert.NoError(t, err)
+	assert.Equal(t, "+16502530000", normalized)
+
+	_, err = qrcard.NormalizePhoneNumber("0171 1234567", "")
+	assert.Error(t, err)
+	_, err = qrcard.NormalizePhoneNumber("", "DE")
+	assert.Error(t, err)
+}
+
+func TestNormalizePhoneNumbers(t *testing.T) {
+	card := vcard.Card{}
+	card.Add(vcard.FieldTelephone, &vcard.Field{Value: "0171 / 123 45-67", Params: vcard.Params{vcard.ParamType: {vcard.TypeCell}}})
+	card.Add(vcard.FieldTelephone, &vcard.Field{Value: "+491711234567"})
+	card.Add(vcard.FieldTelephone, &vcard.Field{Value: "call me"})
+
+	changes := qrcard.NormalizePhoneNumbers(card, "DE")
+	assert.Equal(t, []qrcard.PhoneNumberChange{{From: "0171 / 123 45-67", To: "+491711234567"}}, changes)
+	assert.Equal(t, []string{"+491711234567", "+491711234567", "call me"}, card.Values(vcard.FieldTelephone))
+	assert.Equal(t, []string{vcard.TypeCell}, card[vcard.FieldTelephone][0].Params[vcard.ParamType])
+}
@@ -0,0 +1,154 @@
+package qrcard
+
+import (
+	"regexp"
+	"strings"
+)
+
+// postalCodeFormats are the formats of the postal codes of countries by their two letter country code, letters are upper case.
+// The formats are the postal code patterns of the address metadata of Google's libaddressinput, which is the address data of the CLDR,
+// for the countries whose postal codes are in common use. The postal codes of all other countries can not be checked.
+var postalCodeFormats = map[string]*regexp.Regexp{
+	"AR": regexp.MustCompile(`^([A-HJ-NP-Z])?\d{4}([A-Z]{3})?$`),
+	"AT": regexp.MustCompile(`^\d{4}$`),
+	"AU": regexp.MustCompile(`^\d{4}$`),
+	"BE": regexp.MustCompile(`^\d{4}$`),
+	"BG": regexp.MustCompile(`^\d{4}$`),
+	"BR": regexp.MustCompile(`^\d{5}-?\d{3}$`),
+	"CA": regexp.MustCompile(`^[ABCEGHJKLMNPRSTVXY]\d[ABCEGHJ-NPRSTV-Z] ?\d[ABCEGHJ-NPRSTV-Z]\d$`),
+	"CH": regexp.MustCompile(`^\d{4}$`),
+	"CN": regexp.MustCompile(`^\d{6}$`),
+	"CZ": regexp.MustCompile(`^\d{3} ?\d{2}$`),
+	"DE": regexp.MustCompile(`^\d{5}$`),
+	"DK": regexp.MustCompile(`^\d{4}$`),
+	"EE": regexp.MustCompile(`^\d{5}$`),
+	"ES": regexp.MustCompile(`^\d{5}$`),
+	"FI": regexp.MustCompile(`^\d{5}$`),
+	"FR": regexp.MustCompile(`^\d{2} ?\d{3}$`),
+	"GB": regexp.MustCompile(`^([A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}|GIR ?0AA)$`),
+	"GR": regexp.MustCompile(`^\d{3} ?\d{2}$`),
+	"HR": regexp.MustCompile(`^\d{5}$`),
+	"HU": regexp.MustCompile(`^\d{4}$`),
+	"IE": regexp.MustCompile(`^[\dA-Z]{3} ?[\dA-Z]{4}$`),
+	"IN": regexp.MustCompile(`^\d{6}$`),
+	"IS": regexp.MustCompile(`^\d{3}$`),
+	"IT": regexp.MustCompile(`^\d{5}$`),
+	"JP": regexp.MustCompile(`^\d{3}-?\d{4}$`),
+	"KR": regexp.MustCompile(`^\d{5}$`),
+	"LT": regexp.MustCompile(`^(LT-)?\d{5}$`),
+	"LU": regexp.MustCompile(`^(L-)?\d{4}$`),
+	"LV": regexp.MustCompile(`^(LV-)?\d{4}$`),
+	"MX": regexp.MustCompile(`^\d{5}$`),
+	"NL": regexp.MustCompile(`^\d{4} ?[A-Z]{2}$`),
+	"NO": regexp.MustCompile(`^\d{4}$`),
+	"NZ": regexp.MustCompile(`^\d{4}$`),
+	"PL": regexp.MustCompile(`^\d{2}-\d{3}$`),
+	"PT": regexp.MustCompile(`^\d{4}-\d{3}$`),
+	"RO": regexp.MustCompile(`^\d{6}$`),
+	"SE": regexp.MustCompile(`^\d{3} ?\d{2}$`),
+	"SI": regexp.MustCompile(`^(SI-)?\d{4}$`),
+	"SK": regexp.MustCompile(`^\d{3} ?\d{2}$`),
+	"US": regexp.MustCompile(`^\d{5}([ -]\d{4})?$`),
+	"ZA": regexp.MustCompile(`^\d{4}$`),
+}
+
+// countryNames are the English and native names of the countries of postalCodeFormats, in lower case.
+var countryNames = map[string]string{
+	"argentina":                "AR",
+	"austria":                  "AT",
+	"österreich":               "AT",
+	"australia":                "AU",
+	"belgium":                  "BE",
+	"belgië":                   "BE",
+	"belgique":                 "BE",
+	"belgien":                  "BE",
+	"bulgaria":                 "BG",
+	"българия":                 "BG",
+	"brazil":                   "BR",
+	"brasil":                   "BR",
+	"canada":                   "CA",
+	"switzerland":              "CH",
+	"schweiz":                  "CH",
+	"suisse":                   "CH",
+	"svizzera":                 "CH",
+	"china":                    "CN",
+	"中国":                       "CN",
+	"czechia":                  "CZ",
+	"czech republic":           "CZ",
+	"česko":                    "CZ",
+	"germany":                  "DE",
+	"deutschland":              "DE",
+	"denmark":                  "DK",
+	"danmark":                  "DK",
+	"estonia":                  "EE",
+	"eesti":                    "EE",
+	"spain":                    "ES",
+	"españa":                   "ES",
+	"finland":                  "FI",
+	"suomi":                    "FI",
+	"france":                   "FR",
+	"united kingdom":           "GB",
+	"great britain":            "GB",
+	"uk":                       "GB",
+	"greece":                   "GR",
+	"ελλάδα":                   "GR",
+	"croatia":                  "HR",
+	"hrvatska":                 "HR",
+	"hungary":                  "HU",
+	"magyarország":             "HU",
+	"ireland":                  "IE",
+	"éire":                     "IE",
+	"india":                    "IN",
+	"iceland":                  "IS",
+	"ísland":                   "IS",
+	"italy":                    "IT",
+	"italia":                   "IT",
+	"japan":                    "JP",
+	"日本":                       "JP",
+	"south korea":              "KR",
+	"korea":                    "KR",
+	"대한민국":                     "KR",
+	"lithuania":                "LT",
+	"lietuva":                  "LT",
+	"luxembourg":               "LU",
+	"luxemburg":                "LU",
+	"lëtzebuerg":               "LU",
+	"latvia":                   "LV",
+	"latvija":                  "LV",
+	"mexico":                   "MX",
+	"méxico":                   "MX",
+	"netherlands":              "NL",
+	"the netherlands":          "NL",
+	"nederland":                "NL",
+	"norway":                   "NO",
+	"norge":                    "NO",
+	"new zealand":              "NZ",
+	"aotearoa":                 "NZ",
+	"poland":                   "PL",
+	"polska":                   "PL",
+	"portugal":                 "PT",
+	"romania":                  "RO",
+	"românia":                  "RO",
+	"sweden":                   "SE",
+	"sverige":                  "SE",
+	"slovenia":                 "SI",
+	"slovenija":                "SI",
+	"slovakia":                 "SK",
+	"slovensko":                "SK",
+	"united states":            "US",
+	"united states of america": "US",
+	"usa":                      "US",
+	"south africa":             "ZA",
+}
+
+// countryCode returns the two letter country code of a country that is given by its code or name,
+// or an empty string when the country is not known.
+func countryCode(country string) string {
+	country = strings.TrimSpace(country)
+	if code := strings.ToUpper(country); len(code) == 2 {
+		if _, ok := postalCodeFormats[code]; ok {
+			return code
+		}
+	}
+	return countryNames[strings.ToLower(country)]
+}
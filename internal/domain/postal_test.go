@@ -0,0 +1,76 @@
+package qrcard_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	qrcard "github.com/ulfschneider/qrvc/internal/domain"
+)
+
+func TestPostalCodeFormats(t *testing.T) {
+	examples := map[string]struct {
+		valid   []string
+		invalid []string
+	}{
+		"AR": {valid: []string{"1425", "C1425DKF"}, invalid: []string{"142", "C1425DK"}},
+		"AT": {valid: []string{"1010"}, invalid: []string{"101", "10101"}},
+		"AU": {valid: []string{"2000"}, invalid: []string{"200", "NSW 2000"}},
+		"BE": {valid: []string{"1000"}, invalid: []string{"100", "B-1000"}},
+		"BG": {valid: []string{"1000"}, invalid: []string{"100", "10000"}},
+		"BR": {valid: []string{"01310-100", "01310100"}, invalid: []string{"01310", "01310-10"}},
+		"CA": {valid: []string{"K1A 0B1", "M5V3L9"}, invalid: []string{"K1A", "D1A 0B1"}},
+		"CH": {valid: []string{"8001"}, invalid: []string{"800", "CH-8001"}},
+		"CN": {valid: []string{"100000"}, invalid: []string{"10000", "1000000"}},
+		"CZ": {valid: []string{"110 00", "11000"}, invalid: []string{"1100", "110-00"}},
+		"DE": {valid: []string{"10115"}, invalid: []string{"1011", "D-10115"}},
+		"DK": {valid: []string{"1050"}, invalid: []string{"105", "DK-1050"}},
+		"EE": {valid: []string{"10111"}, invalid: []string{"1011", "EE-10111"}},
+		"ES": {valid: []string{"28013"}, invalid: []string{"2801", "E-28013"}},
+		"FI": {valid: []string{"00100"}, invalid: []string{"0010", "FI-00100"}},
+		"FR": {valid: []string{"75008", "75 008"}, invalid: []string{"7500", "F-75008"}},
+		"GB": {valid: []string{"SW1A 1AA", "M1 1AE", "EC1A1BB", "GIR 0AA"}, invalid: []string{"SW1A", "12345"}},
+		"GR": {valid: []string{"105 57", "10557"}, invalid: []string{"1055", "GR-10557"}},
+		"HR": {valid: []string{"10000"}, invalid: []string{"1000", "HR-10000"}},
+		"HU": {valid: []string{"1051"}, invalid: []string{"105", "H-1051"}},
+		"IE": {valid: []string{"D02 X285", "A65F4E2"}, invalid: []string{"D02", "D02 X28"}},
+		"IN": {valid: []string{"110001"}, invalid: []string{"11000", "110 001"}},
+		"IS": {valid: []string{"101"}, invalid: []string{"10", "1010"}},
+		"IT": {valid: []string{"00184"}, invalid: []string{"0018", "I-00184"}},
+		"JP": {valid: []string{"100-0001", "1000001"}, invalid: []string{"100-001", "10-00001"}},
+		"KR": {valid: []string{"03187"}, invalid: []string{"0318", "031-87"}},
+		"LT": {valid: []string{"01100", "LT-01100"}, invalid: []string{"0110", "LV-01100"}},
+		"LU": {valid: []string{"1009", "L-1009"}, invalid: []string{"100", "LU-1009"}},
+		"LV": {valid: []string{"LV-1050", "1050"}, invalid: []string{"105", "LT-1050"}},
+		"MX": {valid: []string{"06000"}, invalid: []string{"0600", "C.P. 06000"}},
+		"NL": {valid: []string{"1012 JS", "1012JS"}, invalid: []string{"1012", "JS 1012"}},
+		"NO": {valid: []string{"0150"}, invalid: []string{"015", "N-0150"}},
+		"NZ": {valid: []string{"6011"}, invalid: []string{"601", "60111"}},
+		"PL": {valid: []string{"00-950"}, invalid: []string{"00950", "0-950"}},
+		"PT": {valid: []string{"1000-001"}, invalid: []string{"1000", "1000001"}},
+		"RO": {valid: []string{"010011"}, invalid: []string{"01001", "RO-010011"}},
+		"SE": {valid: []string{"111 52", "11152"}, invalid: []string{"1115", "S-11152"}},
+		"SI": {valid: []string{"1000", "SI-1000"}, invalid: []string{"100", "SLO-1000"}},
+		"SK": {valid: []string{"811 01", "81101"}, invalid: []string{"8110", "SK-81101"}},
+		"US": {valid: []string{"94043", "94043-1351"}, invalid: []string{"9404", "94043-135"}},
+		"ZA": {valid: []string{"8001"}, invalid: []string{"800", "80011"}},
+	}
+
+	//every country that is checked has examples
+	assert.Len(t, examples, len(qrcard.PostalCodeFormats))
+	for code, example := range examples {
+		assert.Contains(t, qrcard.PostalCodeFormats, code)
+		for _, postalCode := range example.valid {
+			assert.NoError(t, qrcard.ValidatePostalCode(postalCode, code, ""), code)
+		}
+		for _, postalCode := range example.invalid {
+			assert.Error(t, qrcard.ValidatePostalCode(postalCode, code, ""), code)
+		}
+	}
+
+	//every name stands for its country
+	for name, code := range qrcard.CountryNames {
+		assert.NoError(t, qrcard.ValidatePostalCode(examples[code].valid[0], name, ""), name)
+		assert.Error(t, qrcard.ValidatePostalCode(examples[code].invalid[0], name, ""), name)
+	}
+}
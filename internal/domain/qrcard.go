@@ -6,6 +6,27 @@ import (
 	"github.com/emersion/go-vcard"
 )
 
+// CopyCard returns a deep copy of the card, changing the copy leaves the card untouched.
+func CopyCard(card vcard.Card) vcard.Card {
+	copied := make(vcard.Card, len(card))
+	for key, fields := range card {
+		for _, field := range fields {
+			copied[key] = append(copied[key], CopyField(field))
+		}
+	}
+	return copied
+}
+
+// CopyField returns a deep copy of the field, including its parameters.
+func CopyField(field *vcard.Field) *vcard.Field {
+	copiedField := *field
+	copiedField.Params = make(vcard.Params, len(field.Params))
+	for param, values := range field.Params {
+		copiedField.Params[param] = slices.Clone(values)
+	}
+	return &copiedField
+}
+
 func TypedVcardFieldValue(card vcard.Card, fieldName, wantType string) string {
 	if wantType == "" {
 		return card.Value(fieldName)
@@ -41,7 +62,10 @@ func SetTypedVcardFieldValue(card vcard.Card, fieldName, wantType, value string)
 		}
 	}
 
-	// no field of that type was found, add one
+	// no field of that type was found, add one unless there is no value
+	if value == "" {
+		return
+	}
 	card.Add(fieldName, &vcard.Field{
 		Value: value,
 		Params: map[string][]string{
@@ -0,0 +1,134 @@
+package qrcard
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/emersion/go-vcard"
+	"github.com/nyaruka/phonenumbers"
+)
+
+// phoneCharacters are the characters of a written phone number, letters are not allowed even though phone keypads know them.
+var phoneCharacters = regexp.MustCompile(`^\+?[\d\s()./-]+$`)
+
+// UncheckedError is a value that could not be checked, like a phone number in national format without a region,
+// or the postal code of a country whose format is not known. It is reported, but it does not make the value invalid.
+type UncheckedError struct {
+	message string
+}
+
+func (e *UncheckedError) Error() string {
+	return e.message
+}
+
+// IsUnchecked tells whether the problem is a value that could not be checked.
+func IsUnchecked(err error) bool {
+	var unchecked *UncheckedError
+	return errors.As(err, &unchecked)
+}
+
+// ValidateCard checks the mail addresses, web addresses, phone numbers and postal codes of the card and returns all problems at once.
+// Phone numbers without country code and addresses without country are checked for the region, which is a two letter country code.
+// The values that could not be checked are returned as UncheckedError among the problems.
+func ValidateCard(card vcard.Card, region string) error {
+	problems := []error{}
+	for _, email := range card.Values(vcard.FieldEmail) {
+		problems = append(problems, ValidateEmail(email))
+	}
+	for _, webAddress := range card.Values(vcard.FieldURL) {
+		problems = append(problems, ValidateURL(webAddress))
+	}
+	for _, phoneNumber := range card.Values(vcard.FieldTelephone) {
+		problems = append(problems, ValidatePhoneNumber(phoneNumber, region))
+	}
+	for _, address := range card.Addresses() {
+		problems = append(problems, ValidatePostalCode(address.PostalCode, address.Country, region))
+	}
+	return errors.Join(problems...)
+}
+
+// ValidateEmail checks a mail address for the syntax of RFC 5322, without a display name.
+// The domain must have a top level domain, to catch typos like jon@example. An empty mail address is valid.
+func ValidateEmail(email string) error {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return nil
+	}
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Name != "" || address.Address != email {
+		return fmt.Errorf("The mail address %s is not valid, use a mail address like jon@example.com", email)
+	}
+	_, domain, _ := strings.Cut(address.Address, "@")
+	if label := domain[strings.LastIndex(domain, ".")+1:]; !strings.Contains(domain, ".") || len(label) < 2 {
+		return fmt.Errorf("The mail address %s has no top level domain, use a mail address like jon@example.com", email)
+	}
+	return nil
+}
+
+// ValidateURL checks for an absolute http or https web address. An empty web address is valid.
+func ValidateURL(webAddress string) error {
+	webAddress = strings.TrimSpace(webAddress)
+	if webAddress == "" {
+		return nil
+	}
+	parsed, err := url.Parse(webAddress)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return fmt.Errorf("The web address %s is not valid, use an address like https://example.com", webAddress)
+	}
+	return nil
+}
+
+// ValidatePhoneNumber checks that the phone number is valid in international format like +49 171 1234567,
+// or in the national format of the region. A national phone number without region can not be checked. An empty phone number is valid.
+func ValidatePhoneNumber(phoneNumber, region string) error {
+	phoneNumber = strings.TrimSpace(phoneNumber)
+	if phoneNumber == "" {
+		return nil
+	}
+	if !phoneCharacters.MatchString(phoneNumber) {
+		return fmt.Errorf("The phone number %s must only have digits, spaces and the characters +()./-", phoneNumber)
+	}
+	if region == "" && !strings.HasPrefix(phoneNumber, "+") {
+		return &UncheckedError{fmt.Sprintf("The phone number %s has no country code and can not be checked, use a number like +49 171 1234567 or give a region", phoneNumber)}
+	}
+	parsed, err := phonenumbers.Parse(phoneNumber, strings.ToUpper(region))
+	if err != nil || !phonenumbers.IsValidNumber(parsed) {
+		return fmt.Errorf("The phone number %s is not valid", phoneNumber)
+	}
+	return nil
+}
+
+// KnownRegion tells whether the region is a two letter country code that phone numbers can be checked for.
+func KnownRegion(region string) bool {
+	return phonenumbers.GetSupportedRegions()[strings.ToUpper(region)]
+}
+
+// ValidatePostalCode checks the postal code against the format of the country, which can be a country code or name.
+// The region is used when there is no country. Postal codes without country and region, and postal codes of countries
+// whose format is not known, can not be checked. An empty postal code is valid.
+func ValidatePostalCode(postalCode, country, region string) error {
+	postalCode = strings.TrimSpace(postalCode)
+	if postalCode == "" {
+		return nil
+	}
+	code := countryCode(country)
+	if strings.TrimSpace(country) == "" {
+		if region == "" {
+			return &UncheckedError{fmt.Sprintf("The postal code %s has no country and can not be checked, give a country or a region", postalCode)}
+		}
+		code = strings.ToUpper(region)
+		country = code
+	}
+	format, ok := postalCodeFormats[code]
+	if !ok {
+		return &UncheckedError{fmt.Sprintf("The postal code %s can not be checked, because the postal codes of %s are not known", postalCode, country)}
+	}
+	if format.MatchString(strings.ToUpper(postalCode)) {
+		return nil
+	}
+	return fmt.Errorf("The postal code %s is not valid in %s", postalCode, country)
+}
@@ -0,0 +1,72 @@
+package qrcard_test
+
+import (
+	"testing"
+
+	"github.com/emersion/go-vcard"
+	"github.com/stretchr/testify/assert"
+	qrcard "github.com/ulfschneider/qrvc/internal/domain"
+	testutil "github.com/ulfschneider/qrvc/internal/test/util"
+)
+
+func TestValidateEmail(t *testing.T) {
+	for _, email := range []string{"", "jon@example.com", "jon.doe+qr@mail.example.co.uk", " jon@example.com "} {
+		assert.NoError(t, qrcard.ValidateEmail(email), email)
+	}
+	for _, email := range []string{"jon@example", "jon@example.c", "jon", "jon@@example.com", "Jon <jon@example.com>", "jon@exa mple.com"} {
+		assert.Error(t, qrcard.ValidateEmail(email), email)
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	for _, webAddress := range []string{"", "https://example.com", "http://example.com/team?name=jon"} {
+		assert.NoError(t, qrcard.ValidateURL(webAddress), webAddress)
+	}
+	for _, webAddress := range []string{"example.com", "ftp://example.com", "https://", "/team"} {
+		assert.Error(t, qrcard.ValidateURL(webAddress), webAddress)
+	}
+}
+
+func TestValidatePhoneNumber(t *testing.T) {
+	assert.NoError(t, qrcard.ValidatePhoneNumber("", ""))
+	assert.NoError(t, qrcard.ValidatePhoneNumber("+49 171 1234567", ""))
+	assert.NoError(t, qrcard.ValidatePhoneNumber("+1 (650) 253-0000", "DE"))
+	assert.NoError(t, qrcard.ValidatePhoneNumber("0171 / 123 45-67", "DE"))
+	assert.NoError(t, qrcard.ValidatePhoneNumber("(650) 253-0000", "us"))
+
+	//national numbers can not be checked without a region
+	assert.True(t, qrcard.IsUnchecked(qrcard.ValidatePhoneNumber("0171 1234567", "")))
+	assert.False(t, qrcard.IsUnchecked(qrcard.ValidatePhoneNumber("0171 12", "DE")))
+	//letters are not allowed
+	assert.False(t, qrcard.IsUnchecked(qrcard.ValidatePhoneNumber("+1 800 FLOWERS", "")))
+	assert.Error(t, qrcard.ValidatePhoneNumber("800 FLOWERS", ""))
+	assert.Error(t, qrcard.ValidatePhoneNumber("+49 12", ""))
+}
+
+func TestValidatePostalCode(t *testing.T) {
+	assert.NoError(t, qrcard.ValidatePostalCode("10115", "Germany", ""))
+	assert.NoError(t, qrcard.ValidatePostalCode("10115", "de", ""))
+	assert.NoError(t, qrcard.ValidatePostalCode("sw1a 1aa", "United Kingdom", ""))
+	assert.NoError(t, qrcard.ValidatePostalCode("94043-1351", "", "US"))
+	assert.NoError(t, qrcard.ValidatePostalCode("", "Germany", ""))
+	//postal codes of unknown countries, and without country and region, can not be checked
+	assert.EqualError(t, qrcard.ValidatePostalCode("anything", "Atlantis", "DE"), "The postal code anything can not be checked, because the postal codes of Atlantis are not known")
+	assert.True(t, qrcard.IsUnchecked(qrcard.ValidatePostalCode("anything", "Atlantis", "DE")))
+	assert.True(t, qrcard.IsUnchecked(qrcard.ValidatePostalCode("10115", "", "")))
+
+	assert.Error(t, qrcard.ValidatePostalCode("1011", "Deutschland", ""))
+	assert.False(t, qrcard.IsUnchecked(qrcard.ValidatePostalCode("1234", "", "DE")))
+}
+
+func TestValidateCard(t *testing.T) {
+	assert.NoError(t, qrcard.ValidateCard(testutil.CreateValidCard(), ""))
+
+	err := qrcard.ValidateCard(testutil.CreateCard(), "")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "The mail address Email address is not valid")
+	assert.Contains(t, err.Error(), "The phone number Home phone must only have digits")
+
+	card := testutil.CreateValidCard()
+	card.AddAddress(&vcard.Address{PostalCode: "123", Country: "Germany"})
+	assert.EqualError(t, qrcard.ValidateCard(card, ""), "The postal code 123 is not valid in Germany")
+}
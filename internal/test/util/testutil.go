@@ -1,8 +1,10 @@
 package testutil
 
 import (
+	"embed"
 	"image"
 	"image/draw"
+	"path"
 	"strings"
 
 	"github.com/emersion/go-vcard"
@@ -15,14 +17,29 @@ import (
 	qrcard "github.com/ulfschneider/qrvc/internal/domain"
 )
 
+//go:embed golden/*.vcf
+var golden embed.FS
+
 func NormalizeNewLines(s string) string {
 	return strings.ReplaceAll(s, "\r\n", "\n")
 }
 
+// GoldenVCards returns vCards by their file name, written by hand after the exports of iOS, Android, Google Contacts, Outlook and Thunderbird
+// with their groups, parameters, charsets, folded lines and fields of other applications. They are no real exports, which would hold personal data.
+func GoldenVCards() map[string][]byte {
+	entries, _ := golden.ReadDir("golden")
+	vcards := map[string][]byte{}
+	for _, entry := range entries {
+		vcards[entry.Name()], _ = golden.ReadFile(path.Join("golden", entry.Name()))
+	}
+	return vcards
+}
+
 var ExpectedVCF = NormalizeNewLines(`BEGIN:VCARD
 VERSION:3.0
 ADR:Post office box;Extended street address;Street address;City;;Postal code;Country
 EMAIL:Email address
+FN:Honorific prefix Given name Additional name Family name Honorific suffix
 GENDER:N
 N:Family name;Given name;Additional name;Honorific prefix;Honorific suffix
 ORG:Organization or company;Department
@@ -62,14 +79,43 @@ func CreateCard() vcard.Card {
 	return card
 }
 
+// CreateConformingCard returns the card of CreateCard with the formatted name, which vCard 3.0 requires,
+// so that the card is read back as it has been written.
+func CreateConformingCard() vcard.Card {
+	card := CreateCard()
+	card.SetValue(vcard.FieldFormattedName, "Honorific prefix Given name Additional name Family name Honorific suffix")
+	return card
+}
+
+// CreateValidCard returns the card of CreateCard with mail address, web address, phone numbers and postal code that pass the validation.
+func CreateValidCard() vcard.Card {
+	card := CreateCard()
+	address := card.Address()
+	address.PostalCode = "10115"
+	address.Country = "Germany"
+	card.SetAddress(address)
+	card.SetValue(vcard.FieldEmail, "given.family@example.com")
+	card.SetValue(vcard.FieldURL, "https://example.com")
+	qrcard.SetTypedVcardFieldValue(card, vcard.FieldTelephone, vcard.TypeCell, "+49 171 1234567")
+	qrcard.SetTypedVcardFieldValue(card, vcard.FieldTelephone, vcard.TypeWork, "+49 30 1234567")
+	qrcard.SetTypedVcardFieldValue(card, vcard.FieldTelephone, vcard.TypeHome, "+49 40 1234567")
+	return card
+}
+
+// CreateQRCodec returns a QR codec that reads vCards with the vCard codec.
+func CreateQRCodec() qrcodec.Codec {
+	cardCodec := vcardcodec.NewCodec(config.Settings{})
+	return qrcodec.NewCodec(&cardCodec)
+}
+
 func CreateQRCode(card vcard.Card, settings config.QRCodeSettings) image.Image {
-	codec := qrcodec.NewCodec()
-	img, _ := codec.Encode(card, settings)
+	codec := CreateQRCodec()
+	img, _, _ := codec.Encode(card, settings)
 	return img
 }
 
 func EncodeCard(card vcard.Card) []byte {
-	codec := vcardcodec.NewCodec()
+	codec := vcardcodec.NewCodec(config.Settings{})
 	content, _ := codec.Encode(card)
 	return content
 }
@@ -87,6 +133,8 @@ func (vp *testVersionProvider) Version() string {
 func LoadTestSettings() configcli.CLIFileSettings {
 	var versionService = services.NewVersionService(CreateVersionProvider())
 	var settingsProvider = configcli.NewSettingsProvider(versionService)
+	//the settings of the user running the tests are left out
+	settingsProvider.SetUserConfigDir("")
 
 	settings, _ := settingsProvider.Load()
 	return settings
@@ -2,7 +2,10 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"os"
+	"os/signal"
 
 	"github.com/spf13/afero"
 
@@ -12,6 +15,7 @@ import (
 	configcli "github.com/ulfschneider/qrvc/internal/adapters/config/cli"
 	editorcli "github.com/ulfschneider/qrvc/internal/adapters/editor/cli"
 	notifiercli "github.com/ulfschneider/qrvc/internal/adapters/notifier/cli"
+	previewcli "github.com/ulfschneider/qrvc/internal/adapters/preview/cli"
 	repofile "github.com/ulfschneider/qrvc/internal/adapters/repo/file"
 	versionembedded "github.com/ulfschneider/qrvc/internal/adapters/version/embedded"
 
@@ -20,25 +24,77 @@ import (
 	"github.com/charmbracelet/huh"
 )
 
+// loadLogo reads the logo into the settings before they are handed to the repository and the previewer, which only read them.
+func loadLogo(fileSystem afero.Fs, settings *configcli.CLIFileSettings) error {
+	if settings.Files.LogoPath == "" {
+		return nil
+	}
+
+	logo, err := repofile.LoadLogo(fileSystem, settings.Files.LogoPath)
+	if err != nil {
+		return err
+	}
+	settings.App.QRSettings.Logo = logo
+	return nil
+}
+
 func runQRCard(settings configcli.CLIFileSettings) error {
-	cardCodec := vcardcodec.NewCodec()
-	qrCodec := qrcodec.NewCodec()
+	fileSystem := afero.NewOsFs()
+	if err := loadLogo(fileSystem, &settings); err != nil {
+		return err
+	}
+
+	cardCodec := vcardcodec.NewCodec(settings.App)
+	qrCodec := qrcodec.NewCodec(&cardCodec)
 	repo := repofile.NewRepo(
-		afero.NewOsFs(),
+		fileSystem,
 		&cardCodec,
 		&qrCodec,
 		settings.Files,
 		settings.App)
 
-	editor := editorcli.NewCardEditor()
+	previewer := previewcli.NewQRCodePreviewer(&qrCodec, settings.App)
+	editorOutput := os.Stdout
+	if settings.Files.WritesToStdout() {
+		editorOutput = os.Stderr
+	}
+	editor := editorcli.NewCardEditor(&previewer, &qrCodec, fileSystem, settings.App, editorOutput)
+	userNotifier := notifiercli.NewUserNotifier()
 
-	cardService := services.NewQRCardService(settings.App, &repo, &editor)
+	cardService := services.NewQRCardService(settings.App, &repo, &editor, &previewer, &userNotifier)
 
 	err := cardService.TransformCard()
 
 	return err
 }
 
+func runBatch(settings configcli.CLIFileSettings) error {
+	fileSystem := afero.NewOsFs()
+	if err := loadLogo(fileSystem, &settings); err != nil {
+		return err
+	}
+
+	cardCodec := vcardcodec.NewCodec(settings.App)
+	qrCodec := qrcodec.NewCodec(&cardCodec)
+	repo := repofile.NewRepo(
+		fileSystem,
+		&cardCodec,
+		&qrCodec,
+		settings.Files,
+		settings.App)
+	userNotifier := notifiercli.NewUserNotifier()
+
+	batchService := services.NewBatchService(settings.App, &repo, &userNotifier)
+
+	//stop the batch cleanly when the user presses Ctrl-C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := batchService.TransformCards(ctx)
+
+	return err
+}
+
 func runBOM() error {
 	bomProvider := bomembedded.NewBomProvider()
 	bomService := services.NewBomService(&bomProvider)
@@ -60,21 +116,54 @@ func runVersion() {
 	}
 }
 
+func runPrintConfig(settings configcli.CLIFileSettings) {
+	notifier := notifiercli.NewUserNotifier()
+
+	for _, value := range settings.CLI.ConfigValues {
+		if value.Origin != "" {
+			notifier.NotifyfLoud("%s: %s (%s %s)", value.Name, value.Value, value.Source, value.Origin)
+		} else {
+			notifier.NotifyfLoud("%s: %s (%s)", value.Name, value.Value, value.Source)
+		}
+	}
+}
+
+func runListProfiles(settings configcli.CLIFileSettings) {
+	notifier := notifiercli.NewUserNotifier()
+
+	if len(settings.CLI.Profiles) == 0 {
+		notifier.NotifyLoud("There are no profiles in the config files")
+		return
+	}
+
+	for _, profile := range settings.CLI.Profiles {
+		notifier.SectionLoud()
+		notifier.NotifyfLoud("%s (%s)", profile.Name, profile.Origin)
+		for _, value := range profile.Settings {
+			notifier.NotifyfLoud("  %s: %s", value.Name, value.Value)
+		}
+	}
+}
+
 func finalize(settings configcli.CLIFileSettings, err error) {
 	userNotifier := notifiercli.NewUserNotifier()
-	if errors.Is(err, huh.ErrUserAborted) {
+	if errors.Is(err, huh.ErrUserAborted) || errors.Is(err, context.Canceled) {
 		// User pressed Ctrl-C
 		userNotifier.Notify("You stopped with CTRL-C")
 	} else if err != nil {
 		//any other error
 		userNotifier.Notify(err)
 	}
-	if settings.CLI.Bom == false && settings.CLI.AppVersion == false && settings.App.Silent == false {
+	if settings.CLI.Bom == false && settings.CLI.AppVersion == false && settings.CLI.PrintConfig == false && settings.CLI.ListProfiles == false && settings.App.Silent == false {
 		//say good bye
 		userNotifier.Section()
 		userNotifier.Notify("👋")
 	}
 
+	if err != nil {
+		//let scripts and pipelines know that qrvc failed
+		os.Exit(1)
+	}
 }
 
 func loadConfig() (configcli.CLIFileSettings, error) {
@@ -94,22 +183,31 @@ func main() {
 
 	settings, err := loadConfig()
 	if err != nil {
+		finalize(settings, err)
 		return
 	}
 	defer func() {
 		finalize(settings, err)
 	}()
 
-	if !settings.CLI.Bom && !settings.CLI.AppVersion {
+	if !settings.CLI.Bom && !settings.CLI.AppVersion && !settings.CLI.PrintConfig && !settings.CLI.ListProfiles {
 		userNotifier := notifiercli.NewUserNotifier()
 		userNotifier.Notify("You are running qrvc, a tool to prepare a QR code from a vCard.")
 		userNotifier.Notifyf("Get a list of options by starting the program in the form: %s", "qrvc -h")
 		userNotifier.Notifyf("Stop the program by pressing %s", "CTRL-C")
 		userNotifier.Section()
-		err = runQRCard(settings)
+		if settings.Files.Batch {
+			err = runBatch(settings)
+		} else {
+			err = runQRCard(settings)
+		}
 	} else if settings.CLI.Bom {
 		err = runBOM()
 	} else if settings.CLI.AppVersion {
 		runVersion()
+	} else if settings.CLI.PrintConfig {
+		runPrintConfig(settings)
+	} else if settings.CLI.ListProfiles {
+		runListProfiles(settings)
 	}
 }
@@ -9,7 +9,6 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
-	qrcodec "github.com/ulfschneider/qrvc/internal/adapters/codec/qr"
 	testutil "github.com/ulfschneider/qrvc/internal/test/util"
 )
 
@@ -22,13 +21,28 @@ func TestSmoke(t *testing.T) {
 		t.Fatalf("Build failed: %v\n%s", err, out)
 	}
 
-	card := testutil.CreateCard()
+	//leave out the config files of the user running the tests
+	configFolder := filepath.Join(testFolder, "config")
+	run := func(args ...string) ([]byte, error) {
+		cmd := exec.Command(filepath.Join(testFolder, "qrvc"), args...)
+		cmd.Dir = testFolder
+		cmd.Env = append(os.Environ(), "HOME="+configFolder, "XDG_CONFIG_HOME="+configFolder)
+		return cmd.CombinedOutput()
+	}
+
+	//a card with an invalid mail address fails before anything is written
+	os.WriteFile(filepath.Join(testFolder, "invalid.vcf"), testutil.EncodeCard(testutil.CreateCard()), fs.ModePerm)
+	_, err := run("-s", "-i", filepath.Join(testFolder, "invalid.vcf"), "-o", filepath.Join(testFolder, "invalid-result"))
+	assert.Error(t, err)
+	assert.NoFileExists(t, filepath.Join(testFolder, "invalid-result.vcf"))
+	assert.NoFileExists(t, filepath.Join(testFolder, "invalid-result.png"))
+
+	card := testutil.CreateValidCard()
 	content := testutil.EncodeCard(card)
 	os.WriteFile(filepath.Join(testFolder, "vcard.vcf"), content, fs.ModePerm)
 
 	//create qr code with default settings
-	cmd = exec.Command(filepath.Join(testFolder, "qrvc"), "-s", "-i", filepath.Join(testFolder, "vcard.vcf"), "-o", filepath.Join(testFolder, "result"))
-	if out, err := cmd.CombinedOutput(); err != nil {
+	if out, err := run("-s", "-i", filepath.Join(testFolder, "vcard.vcf"), "-o", filepath.Join(testFolder, "result")); err != nil {
 		t.Fatalf("Smoketest failed: %v\n%s", err, out)
 	}
 
@@ -49,8 +63,8 @@ func TestSmoke(t *testing.T) {
 	//load default test settings
 	settings := testutil.LoadTestSettings()
 
-	codec := qrcodec.NewCodec()
-	expectedQR, err := codec.Encode(card, settings.App.QRSettings)
+	codec := testutil.CreateQRCodec()
+	expectedQR, _, err := codec.Encode(card, settings.App.QRSettings)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, expectedQR)
 	actualQR, format, err := image.Decode(file)